@@ -12,6 +12,9 @@ type BenchMarkOptions struct {
 	Namespace     string
 	UseKubeConfig bool
 	BenchType     string
+	// ProxyKubeConfig kubeconfig for edge proxy, like the one generated by edge proxy with https,
+	// a kubeconfig for http://127.0.0.1:10261 is created if it's empty
+	ProxyKubeConfig string
 }
 
 // NewBenchmarkOptions creates a new BenchMarkOptions with a default config.
@@ -38,4 +41,5 @@ func (o *BenchMarkOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Namespace, "namespace", o.Namespace, "bench mark namespace")
 	fs.StringVar(&o.BenchType, "bench", o.BenchType, "bench type(all|resource|func|filter|consistency)")
 	fs.BoolVar(&o.UseKubeConfig, "use-kubeconfig", o.UseKubeConfig, "use kubeconfig or not. 集群外测试使用")
+	fs.StringVar(&o.ProxyKubeConfig, "proxy-kubeconfig", o.ProxyKubeConfig, "kubeconfig for connecting edge proxy, like {disk-cache-path}/pki/edge-proxy.kubeconfig when edge proxy serves https")
 }
@@ -2,10 +2,11 @@ package config
 
 import (
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"code.aliyun.com/openyurt/edge-proxy/pkg/kubernetes/config"
 	"k8s.io/client-go/rest"
@@ -21,8 +22,72 @@ type EdgeProxyConfiguration struct {
 	RemoteServers       []*url.URL
 	DiskCachePath       string
 	BindAddr            string
-	EdgeProxyServerAddr string
-	EnableSampleHandler bool
+	EdgeProxyServerAddr string // comma separated tcp addresses or unix sockets like unix:///run/edge-proxy.sock
+	// DebugTokenFile file of bearer token for evicting cache by stub server, eviction is disabled if it's empty
+	DebugTokenFile string
+	// OfflineWriteResources resources which writes are queued when remote servers are unhealthy
+	OfflineWriteResources []string
+	// EnableLocalLease serve lease get/update locally when remote servers are unhealthy
+	EnableLocalLease bool
+	// EnableRequestDedup collapse identical in-flight get/list requests
+	EnableRequestDedup bool
+	// EnableWatchMultiplex share one upstream watch among local watchers, WatchHistorySize events are kept for each one
+	EnableWatchMultiplex bool
+	WatchHistorySize     int
+	// EnableAuth authenticate and authorize callers of proxy server
+	EnableAuth bool
+	// EnableImpersonation forward requests as authenticated callers
+	EnableImpersonation bool
+	// EnableHTTPS serve proxy server with https
+	EnableHTTPS       bool
+	TLSCertFile       string
+	TLSPrivateKeyFile string
+	ClientCAFile      string
+	// UnixSocketMode, UnixSocketUID and UnixSocketGID file mode and owner of unix sockets, -1 means owner is not changed
+	UnixSocketMode os.FileMode
+	UnixSocketUID  int
+	UnixSocketGID  int
+	// ShutdownDrainTimeout max duration for draining in-flight requests and cache writes when shutting down
+	ShutdownDrainTimeout time.Duration
+	// Runtime filter, cache and health check settings which can be changed at runtime
+	Runtime *RuntimeConfiguration
+	// Handler name of proxy handler
+	Handler string
+	// Middlewares names of middlewares in proxy handler chain, the first one is the outermost
+	Middlewares []string
+	// MaxRequestsInflight max number of in-flight requests except for watches, 0 means no limit
+	MaxRequestsInflight int
+	// RemoteComponentQPS and RemoteVerbQPS qps of token buckets toward remote servers, 0 means no limit
+	RemoteComponentQPS float64
+	RemoteVerbQPS      map[string]int
+	RemoteBurst        int
+	// PriorityComponents components which are not rate limited
+	PriorityComponents []string
+	// RemoteQueueTimeout max duration for waiting tokens
+	RemoteQueueTimeout time.Duration
+	// ServeStaleOnThrottle serve list from cache when requests are throttled
+	ServeStaleOnThrottle bool
+	// EnableCompression ask remote servers for gzip responses
+	EnableCompression bool
+	// WatchIdleTimeout watches which receive nothing from remote servers for the duration are terminated
+	WatchIdleTimeout time.Duration
+	// AuditLogPath, AuditLogMaxSize and AuditLogMaxBackups audit log and its rotation, audit is disabled if path is empty
+	AuditLogPath       string
+	AuditLogMaxSize    int
+	AuditLogMaxBackups int
+	// AuditPolicyFile audit policy, metadata of all requests are recorded if it's empty
+	AuditPolicyFile string
+	// LogSubsystemVerbosity verbosity of subsystems
+	LogSubsystemVerbosity map[string]int
+	// LogSampleInitial and LogSampleThereafter sampling of logs of hot paths
+	LogSampleInitial    int
+	LogSampleThereafter int
+	// TracingEndpoint, TracingFile and TracingSamplingRatio tracing of requests, it's disabled if endpoint and file are empty
+	TracingEndpoint      string
+	TracingFile          string
+	TracingSamplingRatio float64
+	// RestConfig rest config for remote servers, RT is created from it
+	RestConfig *rest.Config
 }
 
 // Complete converts *options.BenchMarkOptions to *EdgeProxyConfiguration
@@ -33,19 +98,81 @@ func Complete(options *options.EdgeProxyOptions) (*EdgeProxyConfiguration, error
 		return nil, err
 	}
 
+	restCfg, err := prepareRestConfig(options.KubeConfig, options.UseKubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not get rest config, %w", err)
+	}
+
+	socketMode, err := options.ParseUnixSocketMode()
+	if err != nil {
+		return nil, err
+	}
+	socketUID, socketGID, err := options.ParseUnixSocketOwner()
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取 roundTripper 表示执行单个HTTP事务的能力，获得给定请求的响应
-	rt, err := prepareRoundTripper(options.UseKubeConfig)
+	rt, err := newRoundTripper(restCfg, options)
 	if err != nil {
 		return nil, fmt.Errorf("could not new round tripper, %w", err)
 	}
 
+	// clients created by edge proxy should connect to the remote server too
+	restCfg = rest.CopyConfig(restCfg)
+	restCfg.Host = us[0].String()
+
+	handler := options.Handler
+	if options.EnableSampleHandler {
+		// deprecated, it's the same as --handler=sample
+		handler = "sample"
+	}
+
 	cfg := &EdgeProxyConfiguration{
-		RT:                  rt,
-		RemoteServers:       us,
-		DiskCachePath:       options.DiskCachePath,
-		BindAddr:            net.JoinHostPort("127.0.0.1", "10267"),
-		EdgeProxyServerAddr: net.JoinHostPort("127.0.0.1", "10261"),
-		EnableSampleHandler: options.EnableSampleHandler,
+		RT:                    rt,
+		RestConfig:            restCfg,
+		RemoteServers:         us,
+		DiskCachePath:         options.DiskCachePath,
+		BindAddr:              options.BindAddr,
+		DebugTokenFile:        options.DebugTokenFile,
+		EdgeProxyServerAddr:   options.ProxyServerAddr,
+		OfflineWriteResources: options.OfflineWriteResources,
+		EnableLocalLease:      options.EnableLocalLease,
+		EnableRequestDedup:    options.EnableRequestDedup,
+		EnableWatchMultiplex:  options.EnableWatchMultiplex,
+		WatchHistorySize:      options.WatchHistorySize,
+		EnableAuth:            options.EnableAuth,
+		EnableImpersonation:   options.EnableImpersonation,
+		EnableHTTPS:           options.EnableHTTPS,
+		TLSCertFile:           options.TLSCertFile,
+		TLSPrivateKeyFile:     options.TLSPrivateKeyFile,
+		ClientCAFile:          options.ClientCAFile,
+		UnixSocketMode:        socketMode,
+		UnixSocketUID:         socketUID,
+		UnixSocketGID:         socketGID,
+		ShutdownDrainTimeout:  options.ShutdownDrainTimeout,
+		Runtime:               NewRuntimeConfiguration(options),
+		Handler:               handler,
+		Middlewares:           options.Middlewares,
+		MaxRequestsInflight:   options.MaxRequestsInflight,
+		RemoteComponentQPS:    options.RemoteComponentQPS,
+		RemoteVerbQPS:         options.RemoteVerbQPS,
+		RemoteBurst:           options.RemoteBurst,
+		RemoteQueueTimeout:    options.RemoteQueueTimeout,
+		ServeStaleOnThrottle:  options.ServeStaleOnThrottle,
+		PriorityComponents:    options.PriorityComponents,
+		EnableCompression:     options.EnableCompression,
+		WatchIdleTimeout:      options.WatchIdleTimeout,
+		AuditLogPath:          options.AuditLogPath,
+		AuditLogMaxSize:       options.AuditLogMaxSize,
+		AuditLogMaxBackups:    options.AuditLogMaxBackups,
+		AuditPolicyFile:       options.AuditPolicyFile,
+		LogSubsystemVerbosity: options.LogSubsystemVerbosity,
+		LogSampleInitial:      options.LogSampleInitial,
+		LogSampleThereafter:   options.LogSampleThereafter,
+		TracingEndpoint:       options.TracingEndpoint,
+		TracingFile:           options.TracingFile,
+		TracingSamplingRatio:  options.TracingSamplingRatio,
 	}
 
 	return cfg, nil
@@ -66,8 +193,8 @@ func parseRemoteServers(serverAddr string) ([]*url.URL, error) {
 		}
 		if u.Scheme == "" {
 			u.Scheme = "https"
-		} else if u.Scheme != "https" {
-			return us, fmt.Errorf("only https scheme is supported for server address(%s)", serverAddr)
+		} else if u.Scheme != "https" && u.Scheme != "http" {
+			return us, fmt.Errorf("only http and https schemes are supported for server address(%s)", serverAddr)
 		}
 		us = append(us, u)
 		remoteServers = append(remoteServers, u.String())
@@ -81,19 +208,12 @@ func parseRemoteServers(serverAddr string) ([]*url.URL, error) {
 	return us, nil
 }
 
-func prepareRoundTripper(usekubeconfig bool) (http.RoundTripper, error) {
-	var (
-		cfg *rest.Config
-		err error
-	)
-	if usekubeconfig {
-		cfg, err = config.GetRestConf()
-	} else {
-		cfg, err = rest.InClusterConfig()
+func prepareRestConfig(kubeconfig string, usekubeconfig bool) (*rest.Config, error) {
+	if kubeconfig != "" {
+		return config.GetRestConfFromFile(kubeconfig)
 	}
-	if err != nil {
-		return nil, err
+	if usekubeconfig {
+		return config.GetRestConf()
 	}
-
-	return rest.TransportFor(cfg)
+	return rest.InClusterConfig()
 }
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/options"
+)
+
+// RuntimeConfiguration settings which can be changed at runtime by reloading config file,
+// filter rules, cache policies and health check settings are included.
+type RuntimeConfiguration struct {
+	sync.RWMutex
+	filterPrefix        string
+	enableMemoryCache   bool
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+}
+
+// NewRuntimeConfiguration create a RuntimeConfiguration from options
+func NewRuntimeConfiguration(o *options.EdgeProxyOptions) *RuntimeConfiguration {
+	r := &RuntimeConfiguration{}
+	r.set(o)
+	return r
+}
+
+// Update apply options to runtime settings
+func (r *RuntimeConfiguration) Update(o *options.EdgeProxyOptions) {
+	r.Lock()
+	defer r.Unlock()
+	if r.filterPrefix != o.FilterPrefix || r.enableMemoryCache != o.EnableMemoryCache ||
+		r.healthCheckInterval != o.HealthCheckInterval || r.healthCheckTimeout != o.HealthCheckTimeout {
+		klog.Infof("runtime configuration is updated, filter prefix: %q, memory cache: %v, health check interval: %v, timeout: %v",
+			o.FilterPrefix, o.EnableMemoryCache, o.HealthCheckInterval, o.HealthCheckTimeout)
+	}
+	r.set(o)
+}
+
+func (r *RuntimeConfiguration) set(o *options.EdgeProxyOptions) {
+	r.filterPrefix = o.FilterPrefix
+	r.enableMemoryCache = o.EnableMemoryCache
+	r.healthCheckInterval = o.HealthCheckInterval
+	r.healthCheckTimeout = o.HealthCheckTimeout
+}
+
+// FilterPrefix items with name prefix are filtered from list response
+func (r *RuntimeConfiguration) FilterPrefix() string {
+	r.RLock()
+	defer r.RUnlock()
+	return r.filterPrefix
+}
+
+// EnableMemoryCache cache resourceusage list in memory or not
+func (r *RuntimeConfiguration) EnableMemoryCache() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.enableMemoryCache
+}
+
+// HealthCheck return interval and timeout for checking health of remote servers
+func (r *RuntimeConfiguration) HealthCheck() (time.Duration, time.Duration) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.healthCheckInterval, r.healthCheckTimeout
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"k8s.io/client-go/rest"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/options"
+)
+
+// newRoundTripper create a round tripper toward kube-apiserver with connection settings of options,
+// tls and authentication of restCfg are applied like rest.TransportFor.
+func newRoundTripper(restCfg *rest.Config, options *options.EdgeProxyOptions) (http.RoundTripper, error) {
+	tlsConfig, err := rest.TLSConfigFor(restCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dial := (&net.Dialer{Timeout: options.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if restCfg.Dial != nil {
+		dial = restCfg.Dial
+	}
+	proxy := http.ProxyFromEnvironment
+	if restCfg.Proxy != nil {
+		proxy = restCfg.Proxy
+	}
+
+	t := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dial,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: options.TLSHandshakeTimeout,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     options.MaxConnsPerHost,
+		IdleConnTimeout:     options.IdleConnTimeout,
+		// Accept-Encoding is set by proxy, and responses are passed to clients as they are
+		DisableCompression: true,
+	}
+	if options.DisableHTTP2 {
+		// a non-nil empty map disables HTTP/2
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		t2, err := http2.ConfigureTransports(t)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure http2, %w", err)
+		}
+		// dead connections are detected by ping, otherwise requests hang until tcp keepalive fails
+		t2.ReadIdleTimeout = options.HTTP2ReadIdleTimeout
+		t2.PingTimeout = options.HTTP2PingTimeout
+	}
+
+	return rest.HTTPWrappersForConfig(restCfg, t)
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/options"
+)
+
+// configReloadDelay events of config file in this duration are merged into one reload
+const configReloadDelay = 500 * time.Millisecond
+
+// configReloader reload config file and apply runtime settings
+type configReloader struct {
+	// flagOptions options from command line only, config file is applied on a copy of it
+	flagOptions options.EdgeProxyOptions
+	// current options in use
+	current options.EdgeProxyOptions
+	fs      *pflag.FlagSet
+	runtime *config.RuntimeConfiguration
+}
+
+// reload apply config file to runtime settings, an invalid file is ignored and current settings are kept
+func (r *configReloader) reload() {
+	o := r.flagOptions
+	if err := o.ApplyConfigFile(r.fs); err != nil {
+		klog.Errorf("could not reload config file %s, %v", o.ConfigFile, err)
+		return
+	}
+	if err := o.Validate(); err != nil {
+		klog.Errorf("invalid config file %s, %v", o.ConfigFile, err)
+		return
+	}
+	r.runtime.Update(&o)
+
+	// fields other than runtime settings are not applied until restart
+	static := o
+	static.FilterPrefix = r.current.FilterPrefix
+	static.EnableMemoryCache = r.current.EnableMemoryCache
+	static.HealthCheckInterval = r.current.HealthCheckInterval
+	static.HealthCheckTimeout = r.current.HealthCheckTimeout
+	if !reflect.DeepEqual(static, r.current) {
+		klog.Warningf("config file %s is changed, only filter, memory cache and health check settings are applied without restarting", o.ConfigFile)
+	}
+	r.current = o
+}
+
+// watchConfigFile watch config file and reload it when it's changed,
+// the directory is watched so files replaced by rename(like configmap volumes) are handled.
+func watchConfigFile(r *configReloader, stopCh <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	file := filepath.Clean(r.current.ConfigFile)
+	if err = watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		timer := time.NewTimer(configReloadDelay)
+		timer.Stop()
+		for {
+			select {
+			case <-stopCh:
+				klog.Infof("config watcher exit when received stopCh close")
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// ..data is the symlink updated by configmap volumes
+				if filepath.Clean(event.Name) != file && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer.Reset(configReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("config watcher error, %v", err)
+			case <-timer.C:
+				klog.Infof("config file %s is changed, reload it", file)
+				r.reload()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,107 @@
+package options
+
+import (
+	"github.com/spf13/pflag"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/apis/edgeproxy/v1alpha1"
+)
+
+// ApplyConfigFile load ConfigFile and apply it to options, except for flags which are set explicitly in fs
+func (o *EdgeProxyOptions) ApplyConfigFile(fs *pflag.FlagSet) error {
+	if o.ConfigFile == "" {
+		return nil
+	}
+	c, err := v1alpha1.Load(o.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	apply := func(name string, set func()) {
+		if fs == nil || !fs.Changed(name) {
+			set()
+		}
+	}
+	applyString := func(name string, dst *string, v string) {
+		// empty string is not set in file
+		if v != "" {
+			apply(name, func() { *dst = v })
+		}
+	}
+
+	applyString("server-addr", &o.ServerAddr, c.ServerAddr)
+	applyString("kubeconfig", &o.KubeConfig, c.KubeConfig)
+	applyString("disk-cache-path", &o.DiskCachePath, c.DiskCachePath)
+	applyString("handler", &o.Handler, c.Handler)
+	apply("middlewares", func() { o.Middlewares = c.Middlewares })
+	if c.MaxRequestsInflight != nil {
+		apply("max-requests-inflight", func() { o.MaxRequestsInflight = *c.MaxRequestsInflight })
+	}
+
+	applyString("proxy-server-addr", &o.ProxyServerAddr, c.Serving.ProxyServerAddr)
+	applyString("bind-addr", &o.BindAddr, c.Serving.BindAddr)
+	applyString("debug-token-file", &o.DebugTokenFile, c.Serving.DebugTokenFile)
+	applyString("unix-socket-mode", &o.UnixSocketMode, c.Serving.UnixSocketMode)
+	applyString("unix-socket-owner", &o.UnixSocketOwner, c.Serving.UnixSocketOwner)
+	apply("enable-https", func() { o.EnableHTTPS = *c.Serving.EnableHTTPS })
+	applyString("tls-cert-file", &o.TLSCertFile, c.Serving.TLSCertFile)
+	applyString("tls-private-key-file", &o.TLSPrivateKeyFile, c.Serving.TLSPrivateKeyFile)
+	applyString("client-ca-file", &o.ClientCAFile, c.Serving.ClientCAFile)
+
+	apply("shutdown-drain-timeout", func() { o.ShutdownDrainTimeout = c.Serving.ShutdownDrainTimeout.Duration })
+
+	apply("health-check-interval", func() { o.HealthCheckInterval = c.HealthCheck.Interval.Duration })
+	apply("health-check-timeout", func() { o.HealthCheckTimeout = c.HealthCheck.Timeout.Duration })
+
+	apply("enable-memory-cache", func() { o.EnableMemoryCache = *c.Cache.EnableMemoryCache })
+	if len(c.Cache.OfflineWriteResources) != 0 {
+		apply("offline-write-resources", func() { o.OfflineWriteResources = c.Cache.OfflineWriteResources })
+	}
+	apply("enable-local-lease", func() { o.EnableLocalLease = *c.Cache.EnableLocalLease })
+	apply("enable-request-dedup", func() { o.EnableRequestDedup = *c.Cache.EnableRequestDedup })
+	apply("enable-watch-multiplex", func() { o.EnableWatchMultiplex = *c.Cache.EnableWatchMultiplex })
+	apply("watch-history-size", func() { o.WatchHistorySize = *c.Cache.WatchHistorySize })
+
+	apply("filter-prefix", func() { o.FilterPrefix = *c.Filter.Prefix })
+
+	apply("enable-auth", func() { o.EnableAuth = *c.Auth.EnableAuth })
+	apply("enable-impersonation", func() { o.EnableImpersonation = *c.Auth.EnableImpersonation })
+
+	r := c.RateLimit
+	apply("remote-component-qps", func() { o.RemoteComponentQPS = *r.ComponentQPS })
+	apply("remote-burst", func() { o.RemoteBurst = *r.Burst })
+	if len(r.VerbQPS) != 0 {
+		apply("remote-verb-qps", func() { o.RemoteVerbQPS = r.VerbQPS })
+	}
+	apply("remote-priority-components", func() { o.PriorityComponents = r.PriorityComponents })
+	apply("remote-queue-timeout", func() { o.RemoteQueueTimeout = r.QueueTimeout.Duration })
+	apply("serve-stale-on-throttle", func() { o.ServeStaleOnThrottle = *r.ServeStaleCache })
+
+	t := c.Transport
+	apply("remote-dial-timeout", func() { o.DialTimeout = t.DialTimeout.Duration })
+	apply("remote-tls-handshake-timeout", func() { o.TLSHandshakeTimeout = t.TLSHandshakeTimeout.Duration })
+	apply("remote-max-idle-conns-per-host", func() { o.MaxIdleConnsPerHost = *t.MaxIdleConnsPerHost })
+	apply("remote-max-conns-per-host", func() { o.MaxConnsPerHost = *t.MaxConnsPerHost })
+	apply("remote-idle-conn-timeout", func() { o.IdleConnTimeout = t.IdleConnTimeout.Duration })
+	apply("remote-disable-http2", func() { o.DisableHTTP2 = *t.DisableHTTP2 })
+	apply("remote-http2-read-idle-timeout", func() { o.HTTP2ReadIdleTimeout = t.HTTP2ReadIdleTimeout.Duration })
+	apply("remote-http2-ping-timeout", func() { o.HTTP2PingTimeout = t.HTTP2PingTimeout.Duration })
+	apply("enable-remote-compression", func() { o.EnableCompression = *t.EnableCompression })
+	apply("watch-idle-timeout", func() { o.WatchIdleTimeout = t.WatchIdleTimeout.Duration })
+
+	applyString("audit-log-path", &o.AuditLogPath, c.Audit.LogPath)
+	apply("audit-log-maxsize", func() { o.AuditLogMaxSize = *c.Audit.MaxSize })
+	apply("audit-log-maxbackup", func() { o.AuditLogMaxBackups = *c.Audit.MaxBackups })
+	applyString("audit-policy-file", &o.AuditPolicyFile, c.Audit.PolicyFile)
+
+	if len(c.Logging.SubsystemVerbosity) != 0 {
+		apply("log-subsystem-verbosity", func() { o.LogSubsystemVerbosity = c.Logging.SubsystemVerbosity })
+	}
+	apply("log-sampling-initial", func() { o.LogSampleInitial = *c.Logging.SamplingInitial })
+	apply("log-sampling-thereafter", func() { o.LogSampleThereafter = *c.Logging.SamplingThereafter })
+
+	applyString("tracing-endpoint", &o.TracingEndpoint, c.Tracing.Endpoint)
+	applyString("tracing-file", &o.TracingFile, c.Tracing.File)
+	apply("tracing-sampling-ratio", func() { o.TracingSamplingRatio = *c.Tracing.SamplingRatio })
+
+	return nil
+}
@@ -2,8 +2,18 @@ package options
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/apis/edgeproxy/v1alpha1"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 )
 
 // EdgeProxyOptions is the main settings for the edge-proxy
@@ -13,13 +23,137 @@ type EdgeProxyOptions struct {
 	Version             bool
 	EnableSampleHandler bool
 	UseKubeConfig       bool
+	// OfflineWriteResources resources which writes are queued when kube-apiserver is unhealthy
+	OfflineWriteResources []string
+	// EnableLocalLease serve lease get/update locally when kube-apiserver is unhealthy
+	EnableLocalLease bool
+	// EnableRequestDedup collapse identical in-flight get/list requests into one request toward kube-apiserver
+	EnableRequestDedup bool
+	// EnableWatchMultiplex share one watch toward kube-apiserver among local watchers of the same resource
+	EnableWatchMultiplex bool
+	// WatchHistorySize number of recent events kept for each shared watch
+	WatchHistorySize int
+	// EnableAuth authenticate and authorize callers of proxy server
+	EnableAuth bool
+	// EnableImpersonation forward requests as authenticated callers by Impersonate-* headers
+	EnableImpersonation bool
+	// EnableHTTPS serve proxy server with https
+	EnableHTTPS bool
+	// TLSCertFile and TLSPrivateKeyFile serving certificate of proxy server,
+	// a self signed one is created under DiskCachePath if they are not set
+	TLSCertFile       string
+	TLSPrivateKeyFile string
+	// ClientCAFile ca for verifying client certificates
+	ClientCAFile string
+	// ProxyServerAddr comma separated addresses of proxy server, like "127.0.0.1:10261,unix:///run/edge-proxy.sock"
+	ProxyServerAddr string
+	// UnixSocketMode file mode of unix socket in octal, like "0660"
+	UnixSocketMode string
+	// UnixSocketOwner owner of unix socket, the format is "uid:gid"
+	UnixSocketOwner string
+	// BindAddr address of stub server for healthz, profiling
+	BindAddr string
+	// DebugTokenFile file of bearer token for evicting cache by stub server, eviction is disabled if it's empty
+	DebugTokenFile string
+	// KubeConfig kubeconfig for connecting kube-apiserver, in cluster config is used if it's empty
+	KubeConfig string
+	// HealthCheckInterval and HealthCheckTimeout for checking health of kube-apiserver
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// EnableMemoryCache cache resourceusage list in memory
+	EnableMemoryCache bool
+	// FilterPrefix items with name prefix are filtered from list response
+	FilterPrefix string
+	// Handler name of proxy handler
+	Handler string
+	// Middlewares names of middlewares in proxy handler chain, the first one is the outermost
+	Middlewares []string
+	// MaxRequestsInflight max number of in-flight requests except for watches, 0 means no limit
+	MaxRequestsInflight int
+	// RemoteComponentQPS and RemoteVerbQPS qps of token buckets of each component and verb toward kube-apiserver
+	RemoteComponentQPS float64
+	RemoteVerbQPS      map[string]int
+	// RemoteBurst burst of each token bucket
+	RemoteBurst int
+	// PriorityComponents components which are not rate limited
+	PriorityComponents []string
+	// RemoteQueueTimeout max duration for waiting tokens
+	RemoteQueueTimeout time.Duration
+	// ServeStaleOnThrottle serve list from cache when requests are throttled
+	ServeStaleOnThrottle bool
+	// ShutdownDrainTimeout max duration for draining in-flight requests and cache writes when shutting down
+	ShutdownDrainTimeout time.Duration
+	// DialTimeout and TLSHandshakeTimeout timeouts of new connections toward kube-apiserver
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// MaxIdleConnsPerHost and MaxConnsPerHost size of connection pool toward kube-apiserver
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	// IdleConnTimeout max duration an idle connection is kept
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 use HTTP/1.1 toward kube-apiserver
+	DisableHTTP2 bool
+	// HTTP2ReadIdleTimeout and HTTP2PingTimeout ping based health check of HTTP/2 connections
+	HTTP2ReadIdleTimeout time.Duration
+	HTTP2PingTimeout     time.Duration
+	// EnableCompression ask kube-apiserver for gzip responses
+	EnableCompression bool
+	// WatchIdleTimeout watches which receive nothing from kube-apiserver for the duration are terminated
+	WatchIdleTimeout time.Duration
+	// AuditLogPath path of audit log, "-" means stdout, audit is disabled if it's empty
+	AuditLogPath string
+	// AuditLogMaxSize max size of audit log in megabytes before it's rotated
+	AuditLogMaxSize int
+	// AuditLogMaxBackups max number of rotated audit logs
+	AuditLogMaxBackups int
+	// AuditPolicyFile audit policy, metadata of all requests are recorded if it's empty
+	AuditPolicyFile string
+	// LogSubsystemVerbosity verbosity of subsystems, like cache=4
+	LogSubsystemVerbosity map[string]int
+	// LogSampleInitial and LogSampleThereafter sampling of logs of hot paths like access logs
+	LogSampleInitial    int
+	LogSampleThereafter int
+	// TracingEndpoint OTLP/gRPC endpoint of collector, TracingFile file spans are written to
+	TracingEndpoint string
+	TracingFile     string
+	// TracingSamplingRatio ratio of traced requests which are not sampled by callers
+	TracingSamplingRatio float64
+	// ConfigFile versioned configuration file, flags set explicitly take precedence over it
+	ConfigFile string
 }
 
 // NewEdgeProxyOptions creates a new EdgeProxyOptions with a default config.
 func NewEdgeProxyOptions() *EdgeProxyOptions {
 	o := &EdgeProxyOptions{
-		DiskCachePath:       "/etc/kubernetes/cache/",
-		EnableSampleHandler: false,
+		DiskCachePath:        v1alpha1.DefaultDiskCachePath,
+		EnableSampleHandler:  false,
+		ProxyServerAddr:      v1alpha1.DefaultProxyServerAddr,
+		UnixSocketMode:       v1alpha1.DefaultUnixSocketMode,
+		BindAddr:             v1alpha1.DefaultBindAddr,
+		HealthCheckInterval:  v1alpha1.DefaultHealthCheckInterval,
+		HealthCheckTimeout:   v1alpha1.DefaultHealthCheckTimeout,
+		EnableMemoryCache:    v1alpha1.DefaultEnableMemoryCache,
+		EnableRequestDedup:   v1alpha1.DefaultEnableRequestDedup,
+		WatchHistorySize:     v1alpha1.DefaultWatchHistorySize,
+		FilterPrefix:         v1alpha1.DefaultFilterPrefix,
+		ShutdownDrainTimeout: v1alpha1.DefaultShutdownDrainTimeout,
+		Handler:              v1alpha1.DefaultHandler,
+		Middlewares:          append([]string(nil), v1alpha1.DefaultMiddlewares...),
+		RemoteBurst:          v1alpha1.DefaultRemoteBurst,
+		RemoteQueueTimeout:   v1alpha1.DefaultRemoteQueueTimeout,
+		PriorityComponents:   append([]string(nil), v1alpha1.DefaultRemotePriorityComponents...),
+		DialTimeout:          v1alpha1.DefaultDialTimeout,
+		TLSHandshakeTimeout:  v1alpha1.DefaultTLSHandshakeTimeout,
+		MaxIdleConnsPerHost:  v1alpha1.DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:      v1alpha1.DefaultIdleConnTimeout,
+		HTTP2ReadIdleTimeout: v1alpha1.DefaultHTTP2ReadIdleTimeout,
+		HTTP2PingTimeout:     v1alpha1.DefaultHTTP2PingTimeout,
+		EnableCompression:    v1alpha1.DefaultEnableCompression,
+		AuditLogMaxSize:      v1alpha1.DefaultAuditLogMaxSize,
+		AuditLogMaxBackups:   v1alpha1.DefaultAuditLogMaxBackups,
+		LogSampleInitial:     v1alpha1.DefaultLogSamplingInitial,
+		LogSampleThereafter:  v1alpha1.DefaultLogSamplingAfter,
+		TracingSamplingRatio: v1alpha1.DefaultTracingSamplingRatio,
 	}
 	return o
 }
@@ -29,15 +163,296 @@ func (o *EdgeProxyOptions) Validate() error {
 	if len(o.ServerAddr) == 0 {
 		return fmt.Errorf("server-address is empty")
 	}
+	if err := validateServerAddr(o.ServerAddr); err != nil {
+		return err
+	}
+	if _, _, err := net.SplitHostPort(o.BindAddr); err != nil {
+		return fmt.Errorf("invalid bind-addr %s, %v", o.BindAddr, err)
+	}
+	if o.HealthCheckInterval <= 0 || o.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("health-check-interval and health-check-timeout should be positive")
+	}
+	if o.HealthCheckTimeout > o.HealthCheckInterval {
+		return fmt.Errorf("health-check-timeout(%v) should not be greater than health-check-interval(%v)",
+			o.HealthCheckTimeout, o.HealthCheckInterval)
+	}
+	if o.ShutdownDrainTimeout < 0 {
+		return fmt.Errorf("shutdown-drain-timeout should not be negative")
+	}
+	if o.KubeConfig != "" {
+		if _, err := os.Stat(o.KubeConfig); err != nil {
+			return fmt.Errorf("invalid kubeconfig %s, %v", o.KubeConfig, err)
+		}
+	}
+	if o.Handler == "" {
+		return fmt.Errorf("handler is empty")
+	}
+	if o.DiskCachePath == "" {
+		return fmt.Errorf("disk-cache-path is empty")
+	}
+	if o.WatchHistorySize < 1 {
+		return fmt.Errorf("watch-history-size should be positive")
+	}
+	if o.MaxRequestsInflight < 0 {
+		return fmt.Errorf("max-requests-inflight should not be negative")
+	}
+	if err := o.validateRateLimit(); err != nil {
+		return err
+	}
+	if err := o.validateTransport(); err != nil {
+		return err
+	}
+	if o.AuditLogMaxSize < 0 || o.AuditLogMaxBackups < 0 {
+		return fmt.Errorf("audit-log-maxsize and audit-log-maxbackup should not be negative")
+	}
+	if o.DebugTokenFile != "" {
+		if _, err := os.Stat(o.DebugTokenFile); err != nil {
+			return fmt.Errorf("invalid debug-token-file %s, %v", o.DebugTokenFile, err)
+		}
+	}
+	if o.AuditPolicyFile != "" {
+		if _, err := os.Stat(o.AuditPolicyFile); err != nil {
+			return fmt.Errorf("invalid audit-policy-file %s, %v", o.AuditPolicyFile, err)
+		}
+	}
+	if err := o.validateLogging(); err != nil {
+		return err
+	}
+	if o.TracingSamplingRatio < 0 || o.TracingSamplingRatio > 1 {
+		return fmt.Errorf("tracing-sampling-ratio should be in [0, 1]")
+	}
+	if o.EnableImpersonation && !o.EnableAuth {
+		return fmt.Errorf("enable-impersonation requires enable-auth")
+	}
+	if err := o.validateMiddlewares(); err != nil {
+		return err
+	}
+	if err := o.validateProxyServerAddr(); err != nil {
+		return err
+	}
+	if _, err := o.ParseUnixSocketMode(); err != nil {
+		return err
+	}
+	if _, _, err := o.ParseUnixSocketOwner(); err != nil {
+		return err
+	}
+	if (o.TLSCertFile == "") != (o.TLSPrivateKeyFile == "") {
+		return fmt.Errorf("tls-cert-file and tls-private-key-file should be set together")
+	}
+	if !o.EnableHTTPS && (o.TLSCertFile != "" || o.ClientCAFile != "") {
+		return fmt.Errorf("tls-cert-file or client-ca-file is set, but enable-https is false")
+	}
 
 	return nil
 }
 
+// validateRateLimit validate client side rate limit toward kube-apiserver
+func (o *EdgeProxyOptions) validateRateLimit() error {
+	if o.RemoteComponentQPS < 0 {
+		return fmt.Errorf("remote-component-qps should not be negative")
+	}
+	for verb, qps := range o.RemoteVerbQPS {
+		if qps < 0 {
+			return fmt.Errorf("qps of verb %s in remote-verb-qps should not be negative", verb)
+		}
+	}
+	if o.RemoteBurst < 1 {
+		return fmt.Errorf("remote-burst should be positive")
+	}
+	if o.RemoteQueueTimeout < 0 {
+		return fmt.Errorf("remote-queue-timeout should not be negative")
+	}
+	return nil
+}
+
+// validateTransport validate connection settings toward kube-apiserver
+func (o *EdgeProxyOptions) validateTransport() error {
+	if o.DialTimeout < 0 || o.TLSHandshakeTimeout < 0 || o.IdleConnTimeout < 0 {
+		return fmt.Errorf("remote-dial-timeout, remote-tls-handshake-timeout and remote-idle-conn-timeout should not be negative")
+	}
+	if o.MaxIdleConnsPerHost < 0 || o.MaxConnsPerHost < 0 {
+		return fmt.Errorf("remote-max-idle-conns-per-host and remote-max-conns-per-host should not be negative")
+	}
+	if o.HTTP2ReadIdleTimeout < 0 || o.HTTP2PingTimeout < 0 {
+		return fmt.Errorf("remote-http2-read-idle-timeout and remote-http2-ping-timeout should not be negative")
+	}
+	if o.WatchIdleTimeout < 0 {
+		return fmt.Errorf("watch-idle-timeout should not be negative")
+	}
+	return nil
+}
+
+// validateLogging validate verbosity of subsystems and sampling
+func (o *EdgeProxyOptions) validateLogging() error {
+	for name, level := range o.LogSubsystemVerbosity {
+		known := false
+		for _, subsystem := range logs.Subsystems {
+			known = known || subsystem == name
+		}
+		if !known {
+			return fmt.Errorf("unknown subsystem %s in log-subsystem-verbosity, subsystems are %v", name, logs.Subsystems)
+		}
+		if level < 0 {
+			return fmt.Errorf("verbosity of subsystem %s should not be negative", name)
+		}
+	}
+	if o.LogSampleInitial < 0 || o.LogSampleThereafter < 0 {
+		return fmt.Errorf("log-sampling-initial and log-sampling-thereafter should not be negative")
+	}
+	return nil
+}
+
+// validateMiddlewares callers would be served with credentials of edge proxy if enable-auth is set
+// but authentication or authorization is missing from middlewares, or authorization runs before
+// authentication and finds no user.
+func (o *EdgeProxyOptions) validateMiddlewares() error {
+	if !o.EnableAuth {
+		return nil
+	}
+	authn, authz := -1, -1
+	for i, name := range o.Middlewares {
+		switch name {
+		case "authentication":
+			authn = i
+		case "authorization":
+			authz = i
+		}
+	}
+	if authn < 0 || authz < 0 {
+		return fmt.Errorf("enable-auth requires authentication and authorization in middlewares, got %v", o.Middlewares)
+	}
+	if authn > authz {
+		return fmt.Errorf("authentication should be before authorization in middlewares, got %v", o.Middlewares)
+	}
+	return nil
+}
+
+// validateServerAddr validate addresses of kube-apiserver, http and https are supported
+func validateServerAddr(serverAddr string) error {
+	for _, server := range strings.Split(serverAddr, ",") {
+		u, err := url.Parse(server)
+		if err != nil {
+			return fmt.Errorf("invalid server address %s, %v", server, err)
+		}
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("only http and https schemes are supported for server address(%s)", server)
+		}
+	}
+	return nil
+}
+
+// validateProxyServerAddr validate addresses of proxy server
+func (o *EdgeProxyOptions) validateProxyServerAddr() error {
+	hasTCP := false
+	for _, addr := range strings.Split(o.ProxyServerAddr, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if strings.HasPrefix(addr, "unix://") {
+			if path := strings.TrimPrefix(addr, "unix://"); !filepath.IsAbs(path) {
+				return fmt.Errorf("unix socket path should be absolute, like unix:///run/edge-proxy.sock, got %s", addr)
+			}
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid proxy server address %s, %v", addr, err)
+		}
+		hasTCP = true
+	}
+	if o.EnableHTTPS && !hasTCP {
+		return fmt.Errorf("enable-https requires a tcp address in proxy-server-addr")
+	}
+	return nil
+}
+
+// ParseUnixSocketMode parse file mode of unix socket
+func (o *EdgeProxyOptions) ParseUnixSocketMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(o.UnixSocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid unix-socket-mode %s, it should be in octal like 0660", o.UnixSocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ParseUnixSocketOwner parse uid and gid of unix socket, -1 means not changed
+func (o *EdgeProxyOptions) ParseUnixSocketOwner() (int, int, error) {
+	if o.UnixSocketOwner == "" {
+		return -1, -1, nil
+	}
+	parts := strings.Split(o.UnixSocketOwner, ":")
+	if len(parts) != 2 {
+		return -1, -1, fmt.Errorf("invalid unix-socket-owner %s, the format is uid:gid", o.UnixSocketOwner)
+	}
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return -1, -1, fmt.Errorf("invalid uid of unix-socket-owner %s, %v", o.UnixSocketOwner, err)
+	}
+	gid, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return -1, -1, fmt.Errorf("invalid gid of unix-socket-owner %s, %v", o.UnixSocketOwner, err)
+	}
+	return uid, gid, nil
+}
+
 // AddFlags returns flags for a specific edge proxy by section name
 func (o *EdgeProxyOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.ConfigFile, "config", o.ConfigFile, "the versioned configuration file(edgeproxy.openyurt.io/v1alpha1 EdgeProxyConfiguration), flags set explicitly take precedence over it. filter, cache and health check settings are reloaded when it's changed")
 	fs.StringVar(&o.ServerAddr, "server-addr", o.ServerAddr, "the address of Kubernetes kube-apiserver,the format is: \"server1,server2,...\"")
 	fs.BoolVar(&o.Version, "version", o.Version, "print the version information.")
 	fs.BoolVar(&o.EnableSampleHandler, "enable-sample-handler", o.EnableSampleHandler, "enable sample handler or not.")
+	fs.MarkDeprecated("enable-sample-handler", "use --handler=sample instead")
 	fs.BoolVar(&o.UseKubeConfig, "use-kubeconfig", o.UseKubeConfig, "use kubeconfig or not. 集群外测试使用")
+	fs.StringVar(&o.KubeConfig, "kubeconfig", o.KubeConfig, "the kubeconfig for connecting kube-apiserver, $HOME/.kube/config is used if use-kubeconfig is set and it's empty")
+	fs.StringVar(&o.BindAddr, "bind-addr", o.BindAddr, "the address stub server(healthz, profiling) listen on")
+	fs.StringVar(&o.DebugTokenFile, "debug-token-file", o.DebugTokenFile, "the file of bearer token required by DELETE /v1/cache/{key} of stub server, cache eviction is disabled if it's empty")
+	fs.DurationVar(&o.HealthCheckInterval, "health-check-interval", o.HealthCheckInterval, "the interval for checking health of kube-apiserver")
+	fs.DurationVar(&o.HealthCheckTimeout, "health-check-timeout", o.HealthCheckTimeout, "the timeout of each health check of kube-apiserver")
+	fs.BoolVar(&o.EnableMemoryCache, "enable-memory-cache", o.EnableMemoryCache, "cache list result with resourceusage label in memory")
+	fs.StringVar(&o.FilterPrefix, "filter-prefix", o.FilterPrefix, "items with the name prefix are filtered from list response with filter label")
+	fs.DurationVar(&o.ShutdownDrainTimeout, "shutdown-drain-timeout", o.ShutdownDrainTimeout, "the max duration for draining in-flight requests and cache writes when shutting down, watches are closed immediately")
+	fs.StringVar(&o.Handler, "handler", o.Handler, "the name of proxy handler, run \"edge-proxy handlers\" to list registered handlers")
+	fs.StringSliceVar(&o.Middlewares, "middlewares", o.Middlewares, "the middlewares of proxy handler chain in order, the first one handles requests first. logging and metrics should be after component and requestinfo for labels of requests. authentication and authorization are required in order when enable-auth is set")
+	fs.IntVar(&o.MaxRequestsInflight, "max-requests-inflight", o.MaxRequestsInflight, "the max number of in-flight requests except for watches, 429 is returned when it's reached. 0 means no limit")
+	fs.Float64Var(&o.RemoteComponentQPS, "remote-component-qps", o.RemoteComponentQPS, "the qps of requests of each component toward kube-apiserver, 0 means no limit")
+	fs.StringToIntVar(&o.RemoteVerbQPS, "remote-verb-qps", o.RemoteVerbQPS, "the qps of requests of each verb toward kube-apiserver, like \"list=5,get=20\". verbs not set are not limited")
+	fs.IntVar(&o.RemoteBurst, "remote-burst", o.RemoteBurst, "the burst of token buckets of remote-component-qps and remote-verb-qps")
+	fs.StringSliceVar(&o.PriorityComponents, "remote-priority-components", o.PriorityComponents, "the components which are not rate limited, lease requests are not limited either")
+	fs.DurationVar(&o.RemoteQueueTimeout, "remote-queue-timeout", o.RemoteQueueTimeout, "the max duration a request waits for rate limit tokens, 429 is returned if tokens can not be available in time")
+	fs.BoolVar(&o.ServeStaleOnThrottle, "serve-stale-on-throttle", o.ServeStaleOnThrottle, "serve list from local cache instead of 429 when requests toward kube-apiserver are throttled")
+	fs.DurationVar(&o.DialTimeout, "remote-dial-timeout", o.DialTimeout, "the timeout of dialing kube-apiserver")
+	fs.DurationVar(&o.TLSHandshakeTimeout, "remote-tls-handshake-timeout", o.TLSHandshakeTimeout, "the timeout of tls handshake with kube-apiserver")
+	fs.IntVar(&o.MaxIdleConnsPerHost, "remote-max-idle-conns-per-host", o.MaxIdleConnsPerHost, "the max number of idle connections kept for each kube-apiserver")
+	fs.IntVar(&o.MaxConnsPerHost, "remote-max-conns-per-host", o.MaxConnsPerHost, "the max number of connections toward each kube-apiserver, 0 means no limit")
+	fs.DurationVar(&o.IdleConnTimeout, "remote-idle-conn-timeout", o.IdleConnTimeout, "the max duration an idle connection toward kube-apiserver is kept, 0 means no limit")
+	fs.BoolVar(&o.DisableHTTP2, "remote-disable-http2", o.DisableHTTP2, "use HTTP/1.1 instead of HTTP/2 toward kube-apiserver")
+	fs.DurationVar(&o.HTTP2ReadIdleTimeout, "remote-http2-read-idle-timeout", o.HTTP2ReadIdleTimeout, "a ping is sent when no frame is received from kube-apiserver for the duration, so dead HTTP/2 connections are detected. 0 means disabled")
+	fs.DurationVar(&o.HTTP2PingTimeout, "remote-http2-ping-timeout", o.HTTP2PingTimeout, "the HTTP/2 connection is closed if the ping is not answered in the duration")
+	fs.BoolVar(&o.EnableCompression, "enable-remote-compression", o.EnableCompression, "ask kube-apiserver for gzip responses, responses are decompressed for clients which don't accept gzip, and re-compressed after filtering for clients which accept gzip. Accept-Encoding of clients is passed as it is when it's disabled")
+	fs.DurationVar(&o.WatchIdleTimeout, "watch-idle-timeout", o.WatchIdleTimeout, "watches which receive nothing from kube-apiserver for the duration are terminated, so clients re-watch on a new connection. it should be longer than the interval of bookmarks, 0 means disabled. all watches are terminated when kube-apiserver becomes unhealthy")
+	fs.StringVar(&o.AuditLogPath, "audit-log-path", o.AuditLogPath, "the path of audit log, requests are recorded as json lines with component, verb, resource, source(remote/local/cache), status, latency and bytes. \"-\" means stdout, audit is disabled if it's empty. audit middleware should be after requestinfo and authentication")
+	fs.IntVar(&o.AuditLogMaxSize, "audit-log-maxsize", o.AuditLogMaxSize, "the max size of audit log in megabytes before it's rotated, 0 means no rotation")
+	fs.IntVar(&o.AuditLogMaxBackups, "audit-log-maxbackup", o.AuditLogMaxBackups, "the max number of rotated audit logs to keep")
+	fs.StringVar(&o.AuditPolicyFile, "audit-policy-file", o.AuditPolicyFile, "the audit policy with rules of level(None/Metadata), components, users, verbs, resources, namespaces and sources, the first matched rule is used. metadata of all requests are recorded if it's empty")
+	fs.StringToIntVar(&o.LogSubsystemVerbosity, "log-subsystem-verbosity", o.LogSubsystemVerbosity, fmt.Sprintf("the log verbosity of subsystems, like \"cache=4,remote=2\", logs of a subsystem are enabled by -v or its verbosity. subsystems are %v", logs.Subsystems))
+	fs.IntVar(&o.LogSampleInitial, "log-sampling-initial", o.LogSampleInitial, "the number of logs of a hot path(like access logs of successful requests) written in each second before sampling, 0 means no sampling")
+	fs.IntVar(&o.LogSampleThereafter, "log-sampling-thereafter", o.LogSampleThereafter, "every n-th log of a hot path is written after log-sampling-initial ones in each second, 0 means the rest are dropped")
+	fs.StringVar(&o.TracingEndpoint, "tracing-endpoint", o.TracingEndpoint, "the OTLP/gRPC endpoint of a local OpenTelemetry collector spans are exported to, like \"127.0.0.1:4317\". handler chain stages, cache lookups, filtering and requests toward kube-apiserver are traced, and traceparent is sent to kube-apiserver. tracing is disabled if it and tracing-file are empty")
+	fs.StringVar(&o.TracingFile, "tracing-file", o.TracingFile, "the file spans are written to as json lines, \"-\" means stdout")
+	fs.Float64Var(&o.TracingSamplingRatio, "tracing-sampling-ratio", o.TracingSamplingRatio, "the ratio of traced requests in [0, 1], requests with traceparent header follow the sampling decision of callers")
 	fs.StringVar(&o.DiskCachePath, "disk-cache-path", o.DiskCachePath, "the path for kubernetes to storage metadata")
+	fs.StringSliceVar(&o.OfflineWriteResources, "offline-write-resources", o.OfflineWriteResources, "resources(or resource/subresource) which writes are queued and replayed when kube-apiserver is unhealthy, like \"pods/status,events,leases\". empty means writes are rejected. a queued write which gets 409 Conflict on replay is dropped instead of overwriting changes made by others")
+	fs.BoolVar(&o.EnableLocalLease, "enable-local-lease", o.EnableLocalLease, "serve coordination.k8s.io lease get/update locally when kube-apiserver is unhealthy, and push the latest renewal after reconnection")
+	fs.BoolVar(&o.EnableRequestDedup, "enable-request-dedup", o.EnableRequestDedup, "collapse identical in-flight get/list requests(same path, query, Accept and identity) into one request toward kube-apiserver, and fan out the response to all callers")
+	fs.BoolVar(&o.EnableWatchMultiplex, "enable-watch-multiplex", o.EnableWatchMultiplex, "share one watch toward kube-apiserver among local json watches of the same resource, namespace, selectors and identity. watches from a resourceVersion older than the event history are proxied directly")
+	fs.IntVar(&o.WatchHistorySize, "watch-history-size", o.WatchHistorySize, "the number of recent events kept for each shared watch, so local watchers can start from their resourceVersion")
+	fs.BoolVar(&o.EnableAuth, "enable-auth", o.EnableAuth, "authenticate callers by client certificates or bearer tokens(TokenReview), and authorize them by SubjectAccessReview. cached decisions are used when kube-apiserver is unhealthy")
+	fs.BoolVar(&o.EnableImpersonation, "enable-impersonation", o.EnableImpersonation, "forward requests as the authenticated callers by Impersonate-User/Impersonate-Group headers, so RBAC and audit logs of kube-apiserver reflect the real callers. edge proxy should be allowed to impersonate users, groups and userextras")
+	fs.BoolVar(&o.EnableHTTPS, "enable-https", o.EnableHTTPS, "serve proxy server with https. a self signed ca, serving certificate and kubeconfig are created in {disk-cache-path}/pki if tls-cert-file is not set")
+	fs.StringVar(&o.TLSCertFile, "tls-cert-file", o.TLSCertFile, "the serving certificate of proxy server, it's reloaded when the file is rotated")
+	fs.StringVar(&o.TLSPrivateKeyFile, "tls-private-key-file", o.TLSPrivateKeyFile, "the private key of serving certificate")
+	fs.StringVar(&o.ProxyServerAddr, "proxy-server-addr", o.ProxyServerAddr, "the addresses proxy server listen on, tcp address and unix socket are supported, the format is: \"127.0.0.1:10261,unix:///run/edge-proxy.sock\"")
+	fs.StringVar(&o.UnixSocketMode, "unix-socket-mode", o.UnixSocketMode, "the file mode of unix socket in octal")
+	fs.StringVar(&o.UnixSocketOwner, "unix-socket-owner", o.UnixSocketOwner, "the owner of unix socket, the format is: \"uid:gid\"")
+	fs.StringVar(&o.ClientCAFile, "client-ca-file", o.ClientCAFile, "the ca for verifying client certificates of callers")
 }
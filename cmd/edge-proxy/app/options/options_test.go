@@ -0,0 +1,141 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateMiddlewares(t *testing.T) {
+	testcases := map[string]struct {
+		enableAuth  bool
+		middlewares []string
+		err         string
+	}{
+		"default with auth": {
+			enableAuth:  true,
+			middlewares: NewEdgeProxyOptions().Middlewares,
+		},
+		"auth disabled": {
+			middlewares: []string{"requestinfo"},
+		},
+		"authorization missing": {
+			enableAuth:  true,
+			middlewares: []string{"authentication", "requestinfo"},
+			err:         "requires authentication and authorization",
+		},
+		"authentication missing": {
+			enableAuth:  true,
+			middlewares: []string{"requestinfo", "authorization"},
+			err:         "requires authentication and authorization",
+		},
+		"authorization before authentication": {
+			enableAuth:  true,
+			middlewares: []string{"authorization", "requestinfo", "authentication"},
+			err:         "authentication should be before authorization",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			o := NewEdgeProxyOptions()
+			o.EnableAuth = tt.enableAuth
+			o.Middlewares = tt.middlewares
+			err := o.validateMiddlewares()
+			if tt.err == "" && err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expect error with %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeConfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := map[string]struct {
+		mutate func(o *EdgeProxyOptions)
+		err    string
+	}{
+		"defaults": {
+			mutate: func(o *EdgeProxyOptions) {},
+		},
+		"empty server-addr": {
+			mutate: func(o *EdgeProxyOptions) { o.ServerAddr = "" },
+			err:    "server-address is empty",
+		},
+		"http server-addr": {
+			mutate: func(o *EdgeProxyOptions) { o.ServerAddr = "http://127.0.0.1:8080,https://127.0.0.1:6443" },
+		},
+		"unsupported scheme of server-addr": {
+			mutate: func(o *EdgeProxyOptions) { o.ServerAddr = "tcp://127.0.0.1:6443" },
+			err:    "only http and https schemes are supported",
+		},
+		"bind-addr without port": {
+			mutate: func(o *EdgeProxyOptions) { o.BindAddr = "127.0.0.1" },
+			err:    "invalid bind-addr",
+		},
+		"proxy-server-addr with unix socket": {
+			mutate: func(o *EdgeProxyOptions) { o.ProxyServerAddr = "127.0.0.1:10261,unix:///run/edge-proxy.sock" },
+		},
+		"proxy-server-addr without port": {
+			mutate: func(o *EdgeProxyOptions) { o.ProxyServerAddr = "127.0.0.1" },
+			err:    "invalid proxy server address",
+		},
+		"relative unix socket": {
+			mutate: func(o *EdgeProxyOptions) { o.ProxyServerAddr = "unix://run/edge-proxy.sock" },
+			err:    "unix socket path should be absolute",
+		},
+		"zero health-check-interval": {
+			mutate: func(o *EdgeProxyOptions) { o.HealthCheckInterval = 0 },
+			err:    "should be positive",
+		},
+		"health-check-timeout greater than interval": {
+			mutate: func(o *EdgeProxyOptions) {
+				o.HealthCheckInterval, o.HealthCheckTimeout = time.Second, 2*time.Second
+			},
+			err: "should not be greater than health-check-interval",
+		},
+		"empty disk-cache-path": {
+			mutate: func(o *EdgeProxyOptions) { o.DiskCachePath = "" },
+			err:    "disk-cache-path is empty",
+		},
+		"zero watch-history-size": {
+			mutate: func(o *EdgeProxyOptions) { o.WatchHistorySize = 0 },
+			err:    "watch-history-size should be positive",
+		},
+		"existing kubeconfig": {
+			mutate: func(o *EdgeProxyOptions) { o.KubeConfig = kubeConfig },
+		},
+		"missing kubeconfig": {
+			mutate: func(o *EdgeProxyOptions) { o.KubeConfig = kubeConfig + ".missing" },
+			err:    "invalid kubeconfig",
+		},
+		"empty handler": {
+			mutate: func(o *EdgeProxyOptions) { o.Handler = "" },
+			err:    "handler is empty",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			o := NewEdgeProxyOptions()
+			o.ServerAddr = "https://127.0.0.1:6443"
+			tt.mutate(o)
+			err := o.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expect error with %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -12,6 +13,8 @@ import (
 	"code.aliyun.com/openyurt/edge-proxy/pkg/projectinfo"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/server"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/tracing"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 )
 
 // NewCmdStartEdgeProxy creates a *cobra.Command object with default parameters
@@ -33,6 +36,12 @@ func NewCmdStartEdgeProxy(stopCh <-chan struct{}) *cobra.Command {
 				klog.V(1).Infof("FLAG: --%s=%q", flag.Name, flag.Value)
 			})
 
+			// config file is applied to flags which are not set explicitly
+			flagOptions := *edgeProxyOptions
+			if err := edgeProxyOptions.ApplyConfigFile(cmd.Flags()); err != nil {
+				klog.Fatalf("load config file: %v", err)
+			}
+
 			// 校验 proxy options 是否 ok
 			if err := edgeProxyOptions.Validate(); err != nil {
 				klog.Fatalf("validate options: %v", err)
@@ -45,6 +54,18 @@ func NewCmdStartEdgeProxy(stopCh <-chan struct{}) *cobra.Command {
 			}
 			klog.Infof("%s cfg: %#+v", projectinfo.GetProxyName(), edgeProxyCfg)
 
+			if edgeProxyOptions.ConfigFile != "" {
+				reloader := &configReloader{
+					flagOptions: flagOptions,
+					current:     *edgeProxyOptions,
+					fs:          cmd.Flags(),
+					runtime:     edgeProxyCfg.Runtime,
+				}
+				if err = watchConfigFile(reloader, stopCh); err != nil {
+					klog.Fatalf("watch config file %s error, %v", edgeProxyOptions.ConfigFile, err)
+				}
+			}
+
 			if err = Run(edgeProxyCfg, stopCh); err != nil {
 				klog.Fatalf("run %s failed, %v", projectinfo.GetProxyName(), err)
 			}
@@ -52,11 +73,33 @@ func NewCmdStartEdgeProxy(stopCh <-chan struct{}) *cobra.Command {
 	}
 
 	edgeProxyOptions.AddFlags(cmd.Flags())
+	cmd.AddCommand(newCmdListHandlers())
 	return cmd
 }
 
-// Run runs the EdgeProxyConfiguration. This should never exit
+// newCmdListHandlers creates a command for listing registered proxy handlers
+func newCmdListHandlers() *cobra.Command {
+	return &cobra.Command{
+		Use:   "handlers",
+		Short: "List registered proxy handlers, which can be selected by --handler",
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, name := range proxy.ListHandlers() {
+				fmt.Fprintln(cmd.OutOrStdout(), name)
+			}
+		},
+	}
+}
+
+// Run runs the EdgeProxyConfiguration until stopCh is closed
 func Run(cfg *config.EdgeProxyConfiguration, stopCh <-chan struct{}) error {
+	logs.SetSubsystemVerbosity(cfg.LogSubsystemVerbosity)
+	logs.SetSampling(cfg.LogSampleInitial, cfg.LogSampleThereafter)
+	// tracing should be set up before handler chain is built
+	shutdownTracing, err := tracing.Init(cfg.TracingEndpoint, cfg.TracingFile, cfg.TracingSamplingRatio)
+	if err != nil {
+		return fmt.Errorf("could not init tracing, %w", err)
+	}
+
 	trace := 1
 	klog.Infof("%d. new reverse proxy handler for remote servers", trace)
 	// http.Handler
@@ -78,7 +121,25 @@ func Run(cfg *config.EdgeProxyConfiguration, stopCh <-chan struct{}) error {
 		return fmt.Errorf("could not create hub server, %w", err)
 	}
 	// 服务监听 阻塞
-	s.Run()
+	serveErr := s.Run(stopCh)
+
+	// in-flight requests, cache writes of the last responses and spans share one deadline,
+	// so shutting down takes no longer than ShutdownDrainTimeout
+	klog.Infof("shutting down servers, drain timeout: %v", cfg.ShutdownDrainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	defer cancel()
+	if err = s.Shutdown(ctx); err != nil {
+		klog.Errorf("could not shut down servers, %v", err)
+	}
+	if err = proxy.Drain(ctx); err != nil {
+		klog.Errorf("could not drain proxy handler, %v", err)
+	}
+	if err = shutdownTracing(ctx); err != nil {
+		klog.Errorf("could not flush spans, %v", err)
+	}
+	if serveErr != nil {
+		return serveErr
+	}
 	klog.Infof("edge proxy exited")
 	return nil
 }
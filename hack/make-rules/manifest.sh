@@ -96,7 +96,7 @@ spec:
     - --logtostderr=false
     - --stderrthreshold=1
     - --alsologtostderr=true
-    - --enable-sample-handler=true
+    - --handler=dev
     - --log_file=/var/log/edge-proxy/edge-proxy.log
     command:
     - /usr/local/bin/edge-proxy
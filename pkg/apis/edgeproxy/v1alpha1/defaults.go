@@ -0,0 +1,158 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// define default values of edge proxy configuration, they are the defaults of flags too
+const (
+	DefaultDiskCachePath       = "/etc/kubernetes/cache/"
+	DefaultProxyServerAddr     = "127.0.0.1:10261"
+	DefaultBindAddr            = "127.0.0.1:10267"
+	DefaultUnixSocketMode      = "0660"
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 3 * time.Second
+	DefaultEnableMemoryCache   = true
+	DefaultEnableRequestDedup  = false
+	DefaultWatchHistorySize    = 100
+	DefaultFilterPrefix        = "skip-"
+	DefaultHandler             = "dev"
+	// DefaultShutdownDrainTimeout is less than the default terminationGracePeriodSeconds(30s) of pod
+	DefaultShutdownDrainTimeout = 15 * time.Second
+	DefaultRemoteBurst          = 10
+	DefaultRemoteQueueTimeout   = 5 * time.Second
+	// transport defaults are the same as client-go
+	DefaultDialTimeout          = 30 * time.Second
+	DefaultTLSHandshakeTimeout  = 10 * time.Second
+	DefaultMaxIdleConnsPerHost  = 25
+	DefaultIdleConnTimeout      = 90 * time.Second
+	DefaultHTTP2ReadIdleTimeout = 30 * time.Second
+	DefaultHTTP2PingTimeout     = 15 * time.Second
+	DefaultEnableCompression    = false
+	DefaultAuditLogMaxSize      = 100
+	DefaultAuditLogMaxBackups   = 3
+	DefaultLogSamplingInitial   = 5
+	DefaultLogSamplingAfter     = 100
+	DefaultTracingSamplingRatio = 1.0
+)
+
+// DefaultRemotePriorityComponents components which are not limited by client side rate limit
+var DefaultRemotePriorityComponents = []string{"kubelet"}
+
+// DefaultMiddlewares middlewares of proxy handler chain in order, the first one is the outermost
+var DefaultMiddlewares = []string{"requestid", "authentication", "component", "requestinfo", "audit", "logging", "metrics", "maxinflight", "authorization", "resourcecache"}
+
+// SetDefaults set default values for fields which are not set
+func SetDefaults(c *EdgeProxyConfiguration) {
+	if c.DiskCachePath == "" {
+		c.DiskCachePath = DefaultDiskCachePath
+	}
+	if c.Handler == "" {
+		c.Handler = DefaultHandler
+	}
+	if c.Middlewares == nil {
+		c.Middlewares = append([]string(nil), DefaultMiddlewares...)
+	}
+
+	s := &c.Serving
+	if s.ProxyServerAddr == "" {
+		s.ProxyServerAddr = DefaultProxyServerAddr
+	}
+	if s.BindAddr == "" {
+		s.BindAddr = DefaultBindAddr
+	}
+	if s.UnixSocketMode == "" {
+		s.UnixSocketMode = DefaultUnixSocketMode
+	}
+	setDefaultBool(&s.EnableHTTPS, false)
+	if s.ShutdownDrainTimeout == nil {
+		s.ShutdownDrainTimeout = &metav1.Duration{Duration: DefaultShutdownDrainTimeout}
+	}
+
+	if c.HealthCheck.Interval == nil {
+		c.HealthCheck.Interval = &metav1.Duration{Duration: DefaultHealthCheckInterval}
+	}
+	if c.HealthCheck.Timeout == nil {
+		c.HealthCheck.Timeout = &metav1.Duration{Duration: DefaultHealthCheckTimeout}
+	}
+
+	setDefaultBool(&c.Cache.EnableMemoryCache, DefaultEnableMemoryCache)
+	setDefaultBool(&c.Cache.EnableLocalLease, false)
+	setDefaultBool(&c.Cache.EnableRequestDedup, DefaultEnableRequestDedup)
+	setDefaultBool(&c.Cache.EnableWatchMultiplex, false)
+	if c.Cache.WatchHistorySize == nil {
+		size := DefaultWatchHistorySize
+		c.Cache.WatchHistorySize = &size
+	}
+
+	if c.Filter.Prefix == nil {
+		prefix := DefaultFilterPrefix
+		c.Filter.Prefix = &prefix
+	}
+
+	setDefaultBool(&c.Auth.EnableAuth, false)
+	setDefaultBool(&c.Auth.EnableImpersonation, false)
+
+	r := &c.RateLimit
+	if r.ComponentQPS == nil {
+		qps := float64(0)
+		r.ComponentQPS = &qps
+	}
+	if r.Burst == nil {
+		burst := DefaultRemoteBurst
+		r.Burst = &burst
+	}
+	if r.PriorityComponents == nil {
+		r.PriorityComponents = append([]string(nil), DefaultRemotePriorityComponents...)
+	}
+	if r.QueueTimeout == nil {
+		r.QueueTimeout = &metav1.Duration{Duration: DefaultRemoteQueueTimeout}
+	}
+	setDefaultBool(&r.ServeStaleCache, false)
+
+	t := &c.Transport
+	setDefaultDuration(&t.DialTimeout, DefaultDialTimeout)
+	setDefaultDuration(&t.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	setDefaultInt(&t.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	setDefaultInt(&t.MaxConnsPerHost, 0)
+	setDefaultDuration(&t.IdleConnTimeout, DefaultIdleConnTimeout)
+	setDefaultBool(&t.DisableHTTP2, false)
+	setDefaultDuration(&t.HTTP2ReadIdleTimeout, DefaultHTTP2ReadIdleTimeout)
+	setDefaultDuration(&t.HTTP2PingTimeout, DefaultHTTP2PingTimeout)
+	setDefaultBool(&t.EnableCompression, DefaultEnableCompression)
+	setDefaultDuration(&t.WatchIdleTimeout, 0)
+
+	setDefaultInt(&c.Audit.MaxSize, DefaultAuditLogMaxSize)
+	setDefaultInt(&c.Audit.MaxBackups, DefaultAuditLogMaxBackups)
+
+	setDefaultInt(&c.Logging.SamplingInitial, DefaultLogSamplingInitial)
+	setDefaultInt(&c.Logging.SamplingThereafter, DefaultLogSamplingAfter)
+
+	if c.Tracing.SamplingRatio == nil {
+		ratio := DefaultTracingSamplingRatio
+		c.Tracing.SamplingRatio = &ratio
+	}
+}
+
+// setDefaultBool set b to v if it's nil
+func setDefaultBool(b **bool, v bool) {
+	if *b == nil {
+		*b = &v
+	}
+}
+
+// setDefaultInt set i to v if it's nil
+func setDefaultInt(i **int, v int) {
+	if *i == nil {
+		*i = &v
+	}
+}
+
+// setDefaultDuration set d to v if it's nil
+func setDefaultDuration(d **metav1.Duration, v time.Duration) {
+	if *d == nil {
+		*d = &metav1.Duration{Duration: v}
+	}
+}
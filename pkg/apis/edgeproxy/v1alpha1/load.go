@@ -0,0 +1,95 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Load read configuration file, unknown fields are rejected,
+// and defaults are set for fields which are not set.
+func Load(path string) (*EdgeProxyConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &EdgeProxyConfiguration{}
+	if err = yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("could not decode config file %s, %w", path, err)
+	}
+	if err = Validate(c); err != nil {
+		return nil, fmt.Errorf("invalid config file %s, %w", path, err)
+	}
+	SetDefaults(c)
+
+	return c, nil
+}
+
+// Validate validate version and fields of configuration
+func Validate(c *EdgeProxyConfiguration) error {
+	if c.APIVersion != SchemeGroupVersion.String() || c.Kind != Kind {
+		return fmt.Errorf("apiVersion and kind should be %s and %s, got %s and %s",
+			SchemeGroupVersion.String(), Kind, c.APIVersion, c.Kind)
+	}
+	if d := c.HealthCheck.Interval; d != nil && d.Duration <= 0 {
+		return fmt.Errorf("healthCheck.interval should be positive, got %v", d.Duration)
+	}
+	if d := c.HealthCheck.Timeout; d != nil && d.Duration <= 0 {
+		return fmt.Errorf("healthCheck.timeout should be positive, got %v", d.Duration)
+	}
+	if d := c.Serving.ShutdownDrainTimeout; d != nil && d.Duration < 0 {
+		return fmt.Errorf("serving.shutdownDrainTimeout should not be negative, got %v", d.Duration)
+	}
+	if n := c.MaxRequestsInflight; n != nil && *n < 0 {
+		return fmt.Errorf("maxRequestsInflight should not be negative, got %d", *n)
+	}
+	if n := c.Cache.WatchHistorySize; n != nil && *n < 1 {
+		return fmt.Errorf("cache.watchHistorySize should be positive, got %d", *n)
+	}
+	if q := c.RateLimit.ComponentQPS; q != nil && *q < 0 {
+		return fmt.Errorf("rateLimit.componentQPS should not be negative, got %v", *q)
+	}
+	if b := c.RateLimit.Burst; b != nil && *b < 1 {
+		return fmt.Errorf("rateLimit.burst should be positive, got %d", *b)
+	}
+	if d := c.RateLimit.QueueTimeout; d != nil && d.Duration < 0 {
+		return fmt.Errorf("rateLimit.queueTimeout should not be negative, got %v", d.Duration)
+	}
+	for name, d := range map[string]*metav1.Duration{
+		"dialTimeout":          c.Transport.DialTimeout,
+		"tlsHandshakeTimeout":  c.Transport.TLSHandshakeTimeout,
+		"idleConnTimeout":      c.Transport.IdleConnTimeout,
+		"http2ReadIdleTimeout": c.Transport.HTTP2ReadIdleTimeout,
+		"http2PingTimeout":     c.Transport.HTTP2PingTimeout,
+		"watchIdleTimeout":     c.Transport.WatchIdleTimeout,
+	} {
+		if d != nil && d.Duration < 0 {
+			return fmt.Errorf("transport.%s should not be negative, got %v", name, d.Duration)
+		}
+	}
+	if n := c.Transport.MaxIdleConnsPerHost; n != nil && *n < 0 {
+		return fmt.Errorf("transport.maxIdleConnsPerHost should not be negative, got %d", *n)
+	}
+	if n := c.Transport.MaxConnsPerHost; n != nil && *n < 0 {
+		return fmt.Errorf("transport.maxConnsPerHost should not be negative, got %d", *n)
+	}
+	if n := c.Audit.MaxSize; n != nil && *n < 0 {
+		return fmt.Errorf("audit.maxSize should not be negative, got %d", *n)
+	}
+	if n := c.Audit.MaxBackups; n != nil && *n < 0 {
+		return fmt.Errorf("audit.maxBackups should not be negative, got %d", *n)
+	}
+	if n := c.Logging.SamplingInitial; n != nil && *n < 0 {
+		return fmt.Errorf("logging.samplingInitial should not be negative, got %d", *n)
+	}
+	if n := c.Logging.SamplingThereafter; n != nil && *n < 0 {
+		return fmt.Errorf("logging.samplingThereafter should not be negative, got %d", *n)
+	}
+	if r := c.Tracing.SamplingRatio; r != nil && (*r < 0 || *r > 1) {
+		return fmt.Errorf("tracing.samplingRatio should be in [0, 1], got %v", *r)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "proxy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write config err: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `apiVersion: edgeproxy.openyurt.io/v1alpha1
+kind: EdgeProxyConfiguration
+serverAddr: https://10.0.0.1:6443
+healthCheck:
+  interval: 5s
+filter:
+  prefix: ""
+cache:
+  offlineWriteResources: ["pods/status", "events"]
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load err: %v", err)
+	}
+	if c.ServerAddr != "https://10.0.0.1:6443" {
+		t.Errorf("serverAddr = %s", c.ServerAddr)
+	}
+	if c.HealthCheck.Interval.Duration != 5*time.Second || c.HealthCheck.Timeout.Duration != DefaultHealthCheckTimeout {
+		t.Errorf("unexpected health check %v/%v", c.HealthCheck.Interval, c.HealthCheck.Timeout)
+	}
+	if *c.Filter.Prefix != "" {
+		t.Errorf("empty filter prefix should be kept, got %q", *c.Filter.Prefix)
+	}
+	if !*c.Cache.EnableMemoryCache || *c.Auth.EnableAuth {
+		t.Errorf("defaults should be set for cache and auth")
+	}
+	if c.Serving.ProxyServerAddr != DefaultProxyServerAddr || c.DiskCachePath != DefaultDiskCachePath {
+		t.Errorf("defaults should be set for serving and disk cache path")
+	}
+	if len(c.Cache.OfflineWriteResources) != 2 {
+		t.Errorf("offlineWriteResources = %v", c.Cache.OfflineWriteResources)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no version": `serverAddr: https://10.0.0.1:6443`,
+		"unknown version": `apiVersion: edgeproxy.openyurt.io/v1
+kind: EdgeProxyConfiguration`,
+		"unknown field": `apiVersion: edgeproxy.openyurt.io/v1alpha1
+kind: EdgeProxyConfiguration
+serverAddress: https://10.0.0.1:6443`,
+		"negative interval": `apiVersion: edgeproxy.openyurt.io/v1alpha1
+kind: EdgeProxyConfiguration
+healthCheck:
+  interval: -1s`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Errorf("expect error for %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the group name of edge proxy configuration
+const GroupName = "edgeproxy.openyurt.io"
+
+// SchemeGroupVersion is group version of edge proxy configuration
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// Kind is the kind of edge proxy configuration
+const Kind = "EdgeProxyConfiguration"
+
+// EdgeProxyConfiguration is the configuration file of edge proxy, like:
+//
+//	apiVersion: edgeproxy.openyurt.io/v1alpha1
+//	kind: EdgeProxyConfiguration
+//	serverAddr: https://10.0.0.1:6443
+//	healthCheck:
+//	  interval: 10s
+//	filter:
+//	  prefix: skip-
+//
+// flags set explicitly in command line take precedence over the file.
+type EdgeProxyConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ServerAddr addresses of kube-apiserver, the format is: "server1,server2,..."
+	ServerAddr string `json:"serverAddr,omitempty"`
+	// KubeConfig kubeconfig for connecting kube-apiserver
+	KubeConfig string `json:"kubeConfig,omitempty"`
+	// DiskCachePath the path for caching responses of kube-apiserver
+	DiskCachePath string `json:"diskCachePath,omitempty"`
+	// Handler name of proxy handler
+	Handler string `json:"handler,omitempty"`
+	// Middlewares names of middlewares in proxy handler chain, the first one is the outermost
+	Middlewares []string `json:"middlewares,omitempty"`
+	// MaxRequestsInflight max number of in-flight requests except for watches, 0 means no limit
+	MaxRequestsInflight *int `json:"maxRequestsInflight,omitempty"`
+
+	Serving     ServingConfiguration     `json:"serving,omitempty"`
+	HealthCheck HealthCheckConfiguration `json:"healthCheck,omitempty"`
+	Cache       CacheConfiguration       `json:"cache,omitempty"`
+	Filter      FilterConfiguration      `json:"filter,omitempty"`
+	Auth        AuthConfiguration        `json:"auth,omitempty"`
+	RateLimit   RateLimitConfiguration   `json:"rateLimit,omitempty"`
+	Transport   TransportConfiguration   `json:"transport,omitempty"`
+	Audit       AuditConfiguration       `json:"audit,omitempty"`
+	Logging     LoggingConfiguration     `json:"logging,omitempty"`
+	Tracing     TracingConfiguration     `json:"tracing,omitempty"`
+}
+
+// ServingConfiguration listeners of edge proxy, it can not be changed at runtime
+type ServingConfiguration struct {
+	// ProxyServerAddr comma separated tcp addresses or unix sockets like unix:///run/edge-proxy.sock
+	ProxyServerAddr string `json:"proxyServerAddr,omitempty"`
+	// BindAddr address of stub server for healthz, profiling
+	BindAddr string `json:"bindAddr,omitempty"`
+	// DebugTokenFile file of bearer token for evicting cache by stub server
+	DebugTokenFile string `json:"debugTokenFile,omitempty"`
+	// UnixSocketMode file mode of unix socket in octal, like "0660"
+	UnixSocketMode string `json:"unixSocketMode,omitempty"`
+	// UnixSocketOwner owner of unix socket, the format is "uid:gid"
+	UnixSocketOwner string `json:"unixSocketOwner,omitempty"`
+	// EnableHTTPS serve proxy server with https
+	EnableHTTPS       *bool  `json:"enableHTTPS,omitempty"`
+	TLSCertFile       string `json:"tlsCertFile,omitempty"`
+	TLSPrivateKeyFile string `json:"tlsPrivateKeyFile,omitempty"`
+	ClientCAFile      string `json:"clientCAFile,omitempty"`
+	// ShutdownDrainTimeout max duration for draining in-flight requests and cache writes when shutting down
+	ShutdownDrainTimeout *metav1.Duration `json:"shutdownDrainTimeout,omitempty"`
+}
+
+// HealthCheckConfiguration health check of kube-apiserver, it can be changed at runtime
+type HealthCheckConfiguration struct {
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	Timeout  *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// CacheConfiguration cache policies, enableMemoryCache can be changed at runtime
+type CacheConfiguration struct {
+	// EnableMemoryCache cache resourceusage list in memory
+	EnableMemoryCache *bool `json:"enableMemoryCache,omitempty"`
+	// OfflineWriteResources resources which writes are queued when kube-apiserver is unhealthy
+	OfflineWriteResources []string `json:"offlineWriteResources,omitempty"`
+	// EnableLocalLease serve lease get/update locally when kube-apiserver is unhealthy
+	EnableLocalLease *bool `json:"enableLocalLease,omitempty"`
+	// EnableRequestDedup collapse identical in-flight get/list requests into one request toward kube-apiserver
+	EnableRequestDedup *bool `json:"enableRequestDedup,omitempty"`
+	// EnableWatchMultiplex share one watch toward kube-apiserver among local watchers of the same resource
+	EnableWatchMultiplex *bool `json:"enableWatchMultiplex,omitempty"`
+	// WatchHistorySize number of recent events kept for each shared watch
+	WatchHistorySize *int `json:"watchHistorySize,omitempty"`
+}
+
+// FilterConfiguration filter rules of list response, it can be changed at runtime
+type FilterConfiguration struct {
+	// Prefix items with name prefix are filtered from list response
+	Prefix *string `json:"prefix,omitempty"`
+}
+
+// AuthConfiguration authentication and authorization of callers
+type AuthConfiguration struct {
+	EnableAuth          *bool `json:"enableAuth,omitempty"`
+	EnableImpersonation *bool `json:"enableImpersonation,omitempty"`
+}
+
+// RateLimitConfiguration client side rate limit of requests toward kube-apiserver
+type RateLimitConfiguration struct {
+	// ComponentQPS qps of each component, 0 means components are not limited
+	ComponentQPS *float64 `json:"componentQPS,omitempty"`
+	// Burst burst of each token bucket
+	Burst *int `json:"burst,omitempty"`
+	// VerbQPS qps of each verb, like list: 5
+	VerbQPS map[string]int `json:"verbQPS,omitempty"`
+	// PriorityComponents components which are not limited, lease requests are not limited either
+	PriorityComponents []string `json:"priorityComponents,omitempty"`
+	// QueueTimeout max duration for waiting tokens
+	QueueTimeout *metav1.Duration `json:"queueTimeout,omitempty"`
+	// ServeStaleCache serve list from cache when requests are throttled
+	ServeStaleCache *bool `json:"serveStaleCache,omitempty"`
+}
+
+// TransportConfiguration connections toward kube-apiserver, it can not be changed at runtime
+type TransportConfiguration struct {
+	DialTimeout         *metav1.Duration `json:"dialTimeout,omitempty"`
+	TLSHandshakeTimeout *metav1.Duration `json:"tlsHandshakeTimeout,omitempty"`
+	// MaxIdleConnsPerHost and MaxConnsPerHost size of connection pool, 0 of MaxConnsPerHost means no limit
+	MaxIdleConnsPerHost *int             `json:"maxIdleConnsPerHost,omitempty"`
+	MaxConnsPerHost     *int             `json:"maxConnsPerHost,omitempty"`
+	IdleConnTimeout     *metav1.Duration `json:"idleConnTimeout,omitempty"`
+	// DisableHTTP2 use HTTP/1.1 only
+	DisableHTTP2 *bool `json:"disableHTTP2,omitempty"`
+	// HTTP2ReadIdleTimeout and HTTP2PingTimeout a ping is sent when no frame is received for HTTP2ReadIdleTimeout,
+	// and the connection is closed if the ping is not answered in HTTP2PingTimeout
+	HTTP2ReadIdleTimeout *metav1.Duration `json:"http2ReadIdleTimeout,omitempty"`
+	HTTP2PingTimeout     *metav1.Duration `json:"http2PingTimeout,omitempty"`
+	// EnableCompression ask kube-apiserver for gzip responses
+	EnableCompression *bool `json:"enableCompression,omitempty"`
+	// WatchIdleTimeout watches which receive nothing from kube-apiserver for the duration are terminated, 0 means disabled
+	WatchIdleTimeout *metav1.Duration `json:"watchIdleTimeout,omitempty"`
+}
+
+// AuditConfiguration audit log of requests handled by proxy server
+type AuditConfiguration struct {
+	// LogPath path of audit log, "-" means stdout, audit is disabled if it's empty
+	LogPath string `json:"logPath,omitempty"`
+	// MaxSize max size of audit log in megabytes before it's rotated, MaxBackups max number of rotated files
+	MaxSize    *int `json:"maxSize,omitempty"`
+	MaxBackups *int `json:"maxBackups,omitempty"`
+	// PolicyFile audit policy, metadata of all requests are recorded if it's empty
+	PolicyFile string `json:"policyFile,omitempty"`
+}
+
+// LoggingConfiguration verbosity of subsystems and sampling of hot paths, the global verbosity is set by -v
+type LoggingConfiguration struct {
+	// SubsystemVerbosity verbosity of subsystems, like cache: 4, subsystems are proxy, remote, local, cache and server
+	SubsystemVerbosity map[string]int `json:"subsystemVerbosity,omitempty"`
+	// SamplingInitial and SamplingThereafter the first SamplingInitial entries of a hot path in each second are logged,
+	// then every SamplingThereafter-th entry, 0 of SamplingInitial means no sampling
+	SamplingInitial    *int `json:"samplingInitial,omitempty"`
+	SamplingThereafter *int `json:"samplingThereafter,omitempty"`
+}
+
+// TracingConfiguration OpenTelemetry tracing of requests, tracing is disabled if both endpoint and file are empty
+type TracingConfiguration struct {
+	// Endpoint OTLP/gRPC endpoint of a local collector, like 127.0.0.1:4317
+	Endpoint string `json:"endpoint,omitempty"`
+	// File spans are written to the file as json lines, "-" means stdout
+	File string `json:"file,omitempty"`
+	// SamplingRatio ratio of traced requests which are not sampled by callers yet, in [0, 1]
+	SamplingRatio *float64 `json:"samplingRatio,omitempty"`
+}
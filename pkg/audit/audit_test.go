@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyLevelOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	policy := `rules:
+- level: None
+  resources: ["leases", "pods/*"]
+- level: Metadata
+  sources: ["local", "cache"]
+`
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		event *Event
+		level Level
+	}{
+		{&Event{Verb: "update", Resource: "leases", Source: "local"}, LevelNone},
+		{&Event{Verb: "patch", Resource: "pods", Subresource: "status", Source: "local"}, LevelNone},
+		{&Event{Verb: "list", Resource: "pods", Source: "local"}, LevelMetadata},
+		{&Event{Verb: "list", Resource: "configmaps", Source: "cache"}, LevelMetadata},
+		// no rule is matched
+		{&Event{Verb: "list", Resource: "configmaps", Source: "remote"}, LevelNone},
+	}
+	for _, c := range cases {
+		if level := p.LevelOf(c.event); level != c.level {
+			t.Errorf("expect level %s for %+v, got %s", c.level, c.event, level)
+		}
+	}
+
+	if err = os.WriteFile(path, []byte("rules:\n- level: RequestResponse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadPolicy(path); err == nil {
+		t.Errorf("unsupported level should be rejected")
+	}
+}
+
+func TestFileBackendRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	b, err := NewFileBackend(path, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	// rotate when it exceeds 200 bytes
+	b.maxSize = 200
+
+	for i := 0; i < 10; i++ {
+		if err = b.Write(&Event{Verb: "list", Resource: "pods", Source: "remote"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expect audit log %s, %v", name, err)
+		}
+		if len(data) > 200 || !strings.HasSuffix(string(data), "\n") {
+			t.Errorf("unexpected content of %s: %q", name, data)
+		}
+	}
+	if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("at most 2 backups should be kept")
+	}
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	json "github.com/json-iterator/go"
+)
+
+// Backend sink of audit events
+type Backend interface {
+	Write(e *Event) error
+}
+
+// FileBackend write events as json lines to a file, the file is rotated when it exceeds maxSize,
+// and at most maxBackups rotated files are kept like path.1, path.2, the larger one is older.
+// events are written to stdout if path is "-".
+type FileBackend struct {
+	sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileBackend create a FileBackend, maxSizeMB is the max size of file in megabytes, 0 means no rotation
+func NewFileBackend(path string, maxSizeMB, maxBackups int) (*FileBackend, error) {
+	b := &FileBackend{
+		path:       path,
+		maxSize:    int64(maxSizeMB) << 20,
+		maxBackups: maxBackups,
+	}
+	if path == "-" {
+		b.file = os.Stdout
+		b.maxSize = 0
+		return b, nil
+	}
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// open open the file for appending
+func (b *FileBackend) open() error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("could not create dir of audit log %s, %v", b.path, err)
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open audit log %s, %v", b.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	b.file, b.size = f, info.Size()
+	return nil
+}
+
+// Write write e as a json line
+func (b *FileBackend) Write(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	b.Lock()
+	defer b.Unlock()
+	if b.maxSize > 0 && b.size+int64(len(data)) > b.maxSize && b.size > 0 {
+		if err = b.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := b.file.Write(data)
+	b.size += int64(n)
+	return err
+}
+
+// rotate shift backups, and start a new file
+func (b *FileBackend) rotate() error {
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	if b.maxBackups <= 0 {
+		os.Remove(b.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", b.path, b.maxBackups))
+		for i := b.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", b.path, i), fmt.Sprintf("%s.%d", b.path, i+1))
+		}
+		if err := os.Rename(b.path, b.path+".1"); err != nil {
+			return fmt.Errorf("could not rotate audit log %s, %v", b.path, err)
+		}
+	}
+	return b.open()
+}
+
+// Close close the file
+func (b *FileBackend) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	if b.file == os.Stdout {
+		return nil
+	}
+	return b.file.Close()
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"time"
+)
+
+// Event an audit record of a request, it's written as a json line
+type Event struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Component   string    `json:"component,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Verb        string    `json:"verb"`
+	APIGroup    string    `json:"apiGroup,omitempty"`
+	Resource    string    `json:"resource,omitempty"`
+	Subresource string    `json:"subresource,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	// RequestURI path and query of non-resource requests
+	RequestURI string `json:"requestURI,omitempty"`
+	// Source where response is served from, remote, local or cache, see util.SourceRemote
+	Source string `json:"source"`
+	Status int    `json:"status"`
+	// LatencyMillis duration from request is received to response is completed, watches last long
+	LatencyMillis float64 `json:"latencyMs"`
+	// Bytes size of response body
+	Bytes int64 `json:"bytes"`
+	// RequestID id of request, it's the same as the one in access logs
+	RequestID string `json:"requestID,omitempty"`
+}
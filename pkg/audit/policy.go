@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Level amount of information recorded for a request
+type Level string
+
+// levels of audit policy, request and response bodies are never recorded by edge proxy
+const (
+	// LevelNone requests are not recorded
+	LevelNone Level = "None"
+	// LevelMetadata metadata of requests and responses are recorded, like verb, resource, source and status
+	LevelMetadata Level = "Metadata"
+)
+
+// Policy audit policy like kube-apiserver, the level of the first matched rule is used,
+// requests are not recorded if no rule is matched, like:
+//
+//	rules:
+//	- level: None
+//	  resources: ["leases", "events"]
+//	- level: Metadata
+//	  sources: ["local", "cache"]
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule match requests by all fields, an empty field matches all requests
+type PolicyRule struct {
+	Level Level `json:"level"`
+	// Components client components, like kubelet
+	Components []string `json:"components,omitempty"`
+	// Users authenticated user names
+	Users []string `json:"users,omitempty"`
+	Verbs []string `json:"verbs,omitempty"`
+	// Resources resource or resource/subresource, like "pods" or "pods/status"
+	Resources  []string `json:"resources,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Sources where responses are served from, remote, local or cache
+	Sources []string `json:"sources,omitempty"`
+}
+
+// DefaultPolicy record metadata of all requests
+func DefaultPolicy() *Policy {
+	return &Policy{Rules: []PolicyRule{{Level: LevelMetadata}}}
+}
+
+// LoadPolicy read audit policy from file, unknown fields are rejected
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read audit policy %s, %v", path, err)
+	}
+	p := &Policy{}
+	if err = yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("could not parse audit policy %s, %v", path, err)
+	}
+	for i, r := range p.Rules {
+		if r.Level != LevelNone && r.Level != LevelMetadata {
+			return nil, fmt.Errorf("invalid level %q of rule %d in audit policy, only None and Metadata are supported", r.Level, i)
+		}
+	}
+	return p, nil
+}
+
+// LevelOf get the level of e by the first matched rule
+func (p *Policy) LevelOf(e *Event) Level {
+	for i := range p.Rules {
+		if p.Rules[i].matches(e) {
+			return p.Rules[i].Level
+		}
+	}
+	return LevelNone
+}
+
+func (r *PolicyRule) matches(e *Event) bool {
+	// "pods/*" matches all subresources of pods, but not pods
+	resources := matchAny(r.Resources, e.Resource)
+	if e.Subresource != "" {
+		resources = matchAny(r.Resources, e.Resource+"/"+e.Subresource) || matchAny(r.Resources, e.Resource+"/*")
+	}
+	return resources && matchAny(r.Components, e.Component) && matchAny(r.Users, e.User) && matchAny(r.Verbs, e.Verb) &&
+		matchAny(r.Namespaces, e.Namespace) && matchAny(r.Sources, e.Source)
+}
+
+// matchAny empty values match all, "*" matches all too
+func matchAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == "*" || strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
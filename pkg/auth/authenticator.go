@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// define ttl for authentication cache
+const (
+	authnSuccessTTL = 2 * time.Minute
+	authnFailureTTL = 10 * time.Second
+)
+
+// maxCacheEntries expired entries are pruned when cache grows over it
+const maxCacheEntries = 4096
+
+// IsHealthy is func for fetching healthy status of remote server
+type IsHealthy func() bool
+
+// cachedUser authentication result of a bearer token
+type cachedUser struct {
+	user          user.Info
+	authenticated bool
+	expire        time.Time
+}
+
+// Authenticator authenticate callers of edge proxy by client certificates or bearer tokens,
+// bearer tokens are validated by TokenReview when remote server is healthy,
+// and cached results are used when remote server is unhealthy.
+type Authenticator struct {
+	sync.RWMutex
+	client    kubernetes.Interface
+	isHealthy IsHealthy
+	// cache authentication result by sha256 of token
+	cache map[string]*cachedUser
+}
+
+// NewAuthenticator create an authenticator
+func NewAuthenticator(client kubernetes.Interface, isHealthy IsHealthy) *Authenticator {
+	return &Authenticator{
+		client:    client,
+		isHealthy: isHealthy,
+		cache:     make(map[string]*cachedUser),
+	}
+}
+
+// AuthenticateRequest authenticate a request, false will be returned if no credential or credential is invalid
+func (a *Authenticator) AuthenticateRequest(req *http.Request) (user.Info, bool, error) {
+	// client certificate has been verified by tls listener with client ca
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		cert := req.TLS.VerifiedChains[0][0]
+		if cert.Subject.CommonName != "" {
+			return &user.DefaultInfo{
+				Name:   cert.Subject.CommonName,
+				Groups: append(cert.Subject.Organization, user.AllAuthenticated),
+			}, true, nil
+		}
+	}
+
+	token := bearerToken(req)
+	if token == "" {
+		return nil, false, nil
+	}
+	return a.authenticateToken(req.Context(), token)
+}
+
+// authenticateToken authenticate a bearer token by TokenReview or cache
+func (a *Authenticator) authenticateToken(ctx context.Context, token string) (user.Info, bool, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	a.RLock()
+	cached, ok := a.cache[key]
+	a.RUnlock()
+
+	healthy := a.isHealthy()
+	if ok && (!healthy || time.Now().Before(cached.expire)) {
+		// use cached result even it's expired when remote server is unhealthy
+		return cached.user, cached.authenticated, nil
+	}
+	if !healthy {
+		return nil, false, fmt.Errorf("token can not be reviewed when remote server is unhealthy")
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		if ok {
+			klog.Errorf("could not review token, use cached result, %v", err)
+			return cached.user, cached.authenticated, nil
+		}
+		return nil, false, err
+	}
+
+	entry := &cachedUser{
+		authenticated: result.Status.Authenticated,
+		expire:        time.Now().Add(authnFailureTTL),
+	}
+	if result.Status.Authenticated {
+		extra := make(map[string][]string, len(result.Status.User.Extra))
+		for k, v := range result.Status.User.Extra {
+			extra[k] = v
+		}
+		entry.user = &user.DefaultInfo{
+			Name:   result.Status.User.Username,
+			UID:    result.Status.User.UID,
+			Groups: result.Status.User.Groups,
+			Extra:  extra,
+		}
+		entry.expire = time.Now().Add(authnSuccessTTL)
+	}
+
+	a.Lock()
+	a.pruneLocked()
+	a.cache[key] = entry
+	a.Unlock()
+
+	return entry.user, entry.authenticated, nil
+}
+
+// bearerToken get bearer token from Authorization header
+func bearerToken(req *http.Request) string {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	if auth == "" {
+		return ""
+	}
+	parts := strings.SplitN(auth, " ", 3)
+	if len(parts) < 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// pruneLocked remove expired entries when cache is too large, it should be called with a.Lock held
+func (a *Authenticator) pruneLocked() {
+	if len(a.cache) < maxCacheEntries {
+		return
+	}
+	now := time.Now()
+	for key, entry := range a.cache {
+		if now.After(entry.expire) {
+			delete(a.cache, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTokenReviewClient create a fake client which authenticates token "good" as user "alice",
+// reviews is increased for every TokenReview, and err is returned if it is set.
+func newTokenReviewClient(reviews *int, err *error) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		if *err != nil {
+			return true, nil, *err
+		}
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		if review.Spec.Token == "good" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "alice", Groups: []string{"dev"}}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+// newTokenRequest create a request with bearer token
+func newTokenRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	healthy := true
+	authn := NewAuthenticator(newTokenReviewClient(&reviews, &reviewErr), func() bool { return healthy })
+
+	u, ok, err := authn.AuthenticateRequest(newTokenRequest("good"))
+	if err != nil || !ok || u.GetName() != "alice" {
+		t.Fatalf("expect alice is authenticated, got %v, %v, %v", u, ok, err)
+	}
+	if _, ok, _ = authn.AuthenticateRequest(newTokenRequest("good")); !ok || reviews != 1 {
+		t.Fatalf("expect cached result is used, got %v with %d reviews", ok, reviews)
+	}
+
+	if _, ok, err = authn.AuthenticateRequest(newTokenRequest("bad")); ok || err != nil {
+		t.Fatalf("expect bad token is not authenticated, got %v, %v", ok, err)
+	}
+	if _, ok, _ = authn.AuthenticateRequest(newTokenRequest("bad")); ok || reviews != 2 {
+		t.Fatalf("expect failure is cached, got %v with %d reviews", ok, reviews)
+	}
+
+	if _, ok, err = authn.AuthenticateRequest(newTokenRequest("")); ok || err != nil {
+		t.Fatalf("expect request without token is not authenticated, got %v, %v", ok, err)
+	}
+}
+
+func TestAuthenticateTokenOffline(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	healthy := true
+	authn := NewAuthenticator(newTokenReviewClient(&reviews, &reviewErr), func() bool { return healthy })
+	if _, ok, _ := authn.AuthenticateRequest(newTokenRequest("good")); !ok {
+		t.Fatalf("expect good token is authenticated")
+	}
+
+	// cached result is used even it's expired when remote server is unhealthy
+	healthy = false
+	for key := range authn.cache {
+		authn.cache[key].expire = authn.cache[key].expire.Add(-2 * authnSuccessTTL)
+	}
+	if u, ok, err := authn.AuthenticateRequest(newTokenRequest("good")); !ok || err != nil || u.GetName() != "alice" {
+		t.Fatalf("expect cached user when remote server is unhealthy, got %v, %v, %v", u, ok, err)
+	}
+	if _, ok, err := authn.AuthenticateRequest(newTokenRequest("unknown")); ok || err == nil {
+		t.Fatalf("expect unknown token is rejected when remote server is unhealthy, got %v, %v", ok, err)
+	}
+	if reviews != 1 {
+		t.Fatalf("expect no review when remote server is unhealthy, got %d", reviews)
+	}
+
+	// expired result is used when TokenReview fails
+	healthy, reviewErr = true, fmt.Errorf("connection refused")
+	if _, ok, err := authn.AuthenticateRequest(newTokenRequest("good")); !ok || err != nil {
+		t.Fatalf("expect cached user when review fails, got %v, %v", ok, err)
+	}
+	if _, ok, err := authn.AuthenticateRequest(newTokenRequest("unknown")); ok || err == nil {
+		t.Fatalf("expect error when review fails without cache, got %v, %v", ok, err)
+	}
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	authn := NewAuthenticator(newTokenReviewClient(&reviews, &reviewErr), func() bool { return true })
+	req := newTokenRequest("")
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{
+		Subject: pkix.Name{CommonName: "system:node:node-a", Organization: []string{"system:nodes"}},
+	}}}}
+
+	u, ok, err := authn.AuthenticateRequest(req)
+	if err != nil || !ok || u.GetName() != "system:node:node-a" || reviews != 0 {
+		t.Fatalf("expect node is authenticated by certificate, got %v, %v, %v with %d reviews", u, ok, err, reviews)
+	}
+}
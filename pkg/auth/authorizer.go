@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// define ttl for authorization cache, same as the default of webhook authorizer
+const (
+	authzAllowTTL = 5 * time.Minute
+	authzDenyTTL  = 30 * time.Second
+)
+
+// cachedDecision authorization decision of a user for a request
+type cachedDecision struct {
+	allowed bool
+	reason  string
+	expire  time.Time
+}
+
+// Authorizer authorize requests by SubjectAccessReview with a local decision cache,
+// cached decisions are used when remote server is unhealthy.
+type Authorizer struct {
+	sync.RWMutex
+	client    kubernetes.Interface
+	isHealthy IsHealthy
+	cache     map[string]*cachedDecision
+}
+
+// NewAuthorizer create an authorizer
+func NewAuthorizer(client kubernetes.Interface, isHealthy IsHealthy) *Authorizer {
+	return &Authorizer{
+		client:    client,
+		isHealthy: isHealthy,
+		cache:     make(map[string]*cachedDecision),
+	}
+}
+
+// Authorize check user can do the request or not, reason is returned when it's denied
+func (a *Authorizer) Authorize(ctx context.Context, u user.Info, info *apirequest.RequestInfo) (bool, string, error) {
+	spec := subjectAccessReviewSpec(u, info)
+	key := decisionKey(&spec)
+
+	a.RLock()
+	cached, ok := a.cache[key]
+	a.RUnlock()
+
+	healthy := a.isHealthy()
+	if ok && (!healthy || time.Now().Before(cached.expire)) {
+		return cached.allowed, cached.reason, nil
+	}
+	if !healthy {
+		return false, "no cached decision when remote server is unhealthy", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(ctx,
+		&authorizationv1.SubjectAccessReview{Spec: spec}, metav1.CreateOptions{})
+	if err != nil {
+		if ok {
+			klog.Errorf("could not review subject access, use cached decision, %v", err)
+			return cached.allowed, cached.reason, nil
+		}
+		return false, "", err
+	}
+
+	entry := &cachedDecision{
+		allowed: result.Status.Allowed && !result.Status.Denied,
+		reason:  result.Status.Reason,
+		expire:  time.Now().Add(authzDenyTTL),
+	}
+	if entry.allowed {
+		entry.expire = time.Now().Add(authzAllowTTL)
+	}
+
+	a.Lock()
+	a.pruneLocked()
+	a.cache[key] = entry
+	a.Unlock()
+
+	return entry.allowed, entry.reason, nil
+}
+
+// subjectAccessReviewSpec generate SubjectAccessReviewSpec for user and request
+func subjectAccessReviewSpec(u user.Info, info *apirequest.RequestInfo) authorizationv1.SubjectAccessReviewSpec {
+	spec := authorizationv1.SubjectAccessReviewSpec{
+		User:   u.GetName(),
+		UID:    u.GetUID(),
+		Groups: u.GetGroups(),
+	}
+	if extra := u.GetExtra(); len(extra) != 0 {
+		spec.Extra = make(map[string]authorizationv1.ExtraValue, len(extra))
+		for k, v := range extra {
+			spec.Extra[k] = v
+		}
+	}
+
+	if info.IsResourceRequest {
+		spec.ResourceAttributes = &authorizationv1.ResourceAttributes{
+			Namespace:   info.Namespace,
+			Verb:        info.Verb,
+			Group:       info.APIGroup,
+			Version:     info.APIVersion,
+			Resource:    info.Resource,
+			Subresource: info.Subresource,
+			Name:        info.Name,
+		}
+	} else {
+		spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{
+			Path: info.Path,
+			Verb: info.Verb,
+		}
+	}
+
+	return spec
+}
+
+// decisionKey generate a cache key for SubjectAccessReviewSpec
+func decisionKey(spec *authorizationv1.SubjectAccessReviewSpec) string {
+	groups := append([]string{}, spec.Groups...)
+	sort.Strings(groups)
+
+	extras := make([]string, 0, len(spec.Extra))
+	for k, v := range spec.Extra {
+		extras = append(extras, fmt.Sprintf("%s=%s", k, strings.Join(v, ",")))
+	}
+	sort.Strings(extras)
+
+	attrs := ""
+	if ra := spec.ResourceAttributes; ra != nil {
+		attrs = strings.Join([]string{ra.Namespace, ra.Verb, ra.Group, ra.Version, ra.Resource, ra.Subresource, ra.Name}, "/")
+	} else if nra := spec.NonResourceAttributes; nra != nil {
+		attrs = nra.Verb + ":" + nra.Path
+	}
+
+	return strings.Join([]string{spec.User, spec.UID, strings.Join(groups, ","), strings.Join(extras, ";"), attrs}, "|")
+}
+
+// pruneLocked remove expired entries when cache is too large, it should be called with a.Lock held
+func (a *Authorizer) pruneLocked() {
+	if len(a.cache) < maxCacheEntries {
+		return
+	}
+	now := time.Now()
+	for key, entry := range a.cache {
+		if now.After(entry.expire) {
+			delete(a.cache, key)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newSubjectAccessReviewClient create a fake client which only allows alice to list pods,
+// reviews is increased for every SubjectAccessReview, and err is returned if it is set.
+func newSubjectAccessReviewClient(reviews *int, err *error) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		if *err != nil {
+			return true, nil, *err
+		}
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		ra := review.Spec.ResourceAttributes
+		if review.Spec.User == "alice" && ra != nil && ra.Resource == "pods" && ra.Verb == "list" {
+			review.Status.Allowed = true
+		} else {
+			review.Status.Reason = "not allowed"
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+// podsRequestInfo request info of pods with verb
+func podsRequestInfo(verb string) *apirequest.RequestInfo {
+	return &apirequest.RequestInfo{IsResourceRequest: true, Verb: verb, APIVersion: "v1", Namespace: "default", Resource: "pods"}
+}
+
+func TestAuthorize(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	authz := NewAuthorizer(newSubjectAccessReviewClient(&reviews, &reviewErr), func() bool { return true })
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"dev"}}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("list")); !allowed || err != nil {
+			t.Fatalf("expect alice can list pods, got %v, %v", allowed, err)
+		}
+		allowed, reason, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("delete"))
+		if allowed || err != nil || reason != "not allowed" {
+			t.Fatalf("expect alice can not delete pods, got %v, %q, %v", allowed, reason, err)
+		}
+	}
+	if reviews != 2 {
+		t.Fatalf("expect decisions are cached, got %d reviews", reviews)
+	}
+
+	bob := &user.DefaultInfo{Name: "bob", Groups: []string{"dev"}}
+	if allowed, _, _ := authz.Authorize(context.TODO(), bob, podsRequestInfo("list")); allowed || reviews != 3 {
+		t.Fatalf("expect decisions are cached by user, got %v with %d reviews", allowed, reviews)
+	}
+}
+
+func TestAuthorizeOffline(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	healthy := true
+	authz := NewAuthorizer(newSubjectAccessReviewClient(&reviews, &reviewErr), func() bool { return healthy })
+	alice := &user.DefaultInfo{Name: "alice"}
+	if allowed, _, _ := authz.Authorize(context.TODO(), alice, podsRequestInfo("list")); !allowed {
+		t.Fatalf("expect alice can list pods")
+	}
+
+	// cached decision is used even it's expired when remote server is unhealthy
+	healthy = false
+	for key := range authz.cache {
+		authz.cache[key].expire = authz.cache[key].expire.Add(-2 * authzAllowTTL)
+	}
+	if allowed, _, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("list")); !allowed || err != nil {
+		t.Fatalf("expect cached decision when remote server is unhealthy, got %v, %v", allowed, err)
+	}
+	if allowed, _, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("get")); allowed || err != nil {
+		t.Fatalf("expect request without cached decision is denied when remote server is unhealthy, got %v, %v", allowed, err)
+	}
+	if reviews != 1 {
+		t.Fatalf("expect no review when remote server is unhealthy, got %d", reviews)
+	}
+
+	// expired decision is used when SubjectAccessReview fails
+	healthy, reviewErr = true, fmt.Errorf("connection refused")
+	if allowed, _, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("list")); !allowed || err != nil {
+		t.Fatalf("expect cached decision when review fails, got %v, %v", allowed, err)
+	}
+	if allowed, _, err := authz.Authorize(context.TODO(), alice, podsRequestInfo("get")); allowed || err == nil {
+		t.Fatalf("expect error when review fails without cache, got %v, %v", allowed, err)
+	}
+}
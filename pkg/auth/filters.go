@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+)
+
+// WithAuthentication authenticate callers and add user into request context,
+// Authorization header of caller is removed, so requests are forwarded with credentials of edge proxy.
+// requests with Impersonate-* headers are rejected, otherwise callers would impersonate anyone
+// with the privileges of edge proxy.
+func WithAuthentication(handler http.Handler, authn *Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u, ok, err := authn.AuthenticateRequest(req)
+		if err != nil {
+			klog.Errorf("could not authenticate request %s, %v", req.URL.String(), err)
+		}
+		if !ok {
+			util.WriteErrStatus(w, apierrors.NewUnauthorized("Unauthorized"))
+			return
+		}
+		if hasImpersonationHeaders(req.Header) {
+			klog.V(2).Infof("forbidden impersonation of %s for %s", req.URL.String(), u.GetName())
+			util.WriteErrStatus(w, apierrors.NewForbidden(schema.GroupResource{Resource: "users"}, "",
+				fmt.Errorf("impersonation is not supported by edge proxy")))
+			return
+		}
+
+		req.Header.Del("Authorization")
+		req = req.WithContext(apirequest.WithUser(req.Context(), u))
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// WithAuthorization authorize requests of authenticated user,
+// request is classified by classifier if request info is not in request context.
+func WithAuthorization(handler http.Handler, authz *Authorizer, classifier *util.RequestClassifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u, ok := apirequest.UserFrom(req.Context())
+		if !ok {
+			util.WriteErrStatus(w, apierrors.NewUnauthorized("Unauthorized"))
+			return
+		}
+
+		req, err := classifier.Classify(req)
+		if err != nil {
+			util.WriteErrStatus(w, err)
+			return
+		}
+		ctx := req.Context()
+		info, _ := apirequest.RequestInfoFrom(ctx)
+
+		allowed, reason, err := authz.Authorize(ctx, u, info)
+		if err != nil {
+			klog.Errorf("could not authorize %s for %s, %v", util.ReqInfoString(info), u.GetName(), err)
+		}
+		if !allowed {
+			klog.V(2).Infof("forbidden %s for %s, reason: %s", util.ReqInfoString(info), u.GetName(), reason)
+			gr := schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+			util.WriteErrStatus(w, apierrors.NewForbidden(gr, info.Name, fmt.Errorf("%s", reason)))
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// hasImpersonationHeaders check caller asks for impersonation or not
+func hasImpersonationHeaders(h http.Header) bool {
+	for k := range h {
+		k = http.CanonicalHeaderKey(k)
+		switch {
+		case k == authenticationv1.ImpersonateUserHeader,
+			k == authenticationv1.ImpersonateGroupHeader,
+			k == authenticationv1.ImpersonateUIDHeader,
+			strings.HasPrefix(k, authenticationv1.ImpersonateUserExtraHeaderPrefix):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+func TestAuthFilters(t *testing.T) {
+	var (
+		reviews   int
+		reviewErr error
+	)
+	authn := NewAuthenticator(newTokenReviewClient(&reviews, &reviewErr), func() bool { return true })
+	authz := NewAuthorizer(newSubjectAccessReviewClient(&reviews, &reviewErr), func() bool { return true })
+	classifier := util.NewRequestClassifier(&apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	})
+
+	var forwarded *http.Request
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		forwarded = req
+	})
+	handler = WithAuthentication(WithAuthorization(handler, authz, classifier), authn)
+
+	testcases := map[string]struct {
+		method string
+		token  string
+		header map[string]string
+		code   int
+	}{
+		"no token": {
+			method: http.MethodGet,
+			code:   http.StatusUnauthorized,
+		},
+		"invalid token": {
+			method: http.MethodGet,
+			token:  "bad",
+			code:   http.StatusUnauthorized,
+		},
+		"allowed": {
+			method: http.MethodGet,
+			token:  "good",
+			code:   http.StatusOK,
+		},
+		"forbidden": {
+			method: http.MethodPost,
+			token:  "good",
+			code:   http.StatusForbidden,
+		},
+		"impersonate user": {
+			method: http.MethodGet,
+			token:  "good",
+			header: map[string]string{"Impersonate-User": "system:admin"},
+			code:   http.StatusForbidden,
+		},
+		"impersonate extra": {
+			method: http.MethodGet,
+			token:  "good",
+			header: map[string]string{"impersonate-extra-scopes": "view"},
+			code:   http.StatusForbidden,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			forwarded = nil
+			req := httptest.NewRequest(tt.method, "/api/v1/namespaces/default/pods", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			for name, value := range tt.header {
+				req.Header[name] = []string{value}
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("expect %d, got %d, %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				if forwarded != nil {
+					t.Errorf("expect request is not forwarded")
+				}
+				return
+			}
+			if forwarded == nil || forwarded.Header.Get("Authorization") != "" {
+				t.Errorf("expect request is forwarded without Authorization header")
+			}
+			if u, ok := apirequest.UserFrom(forwarded.Context()); !ok || u.GetName() != "alice" {
+				t.Errorf("expect user alice in context, got %v", u)
+			}
+		})
+	}
+}
@@ -56,7 +56,9 @@ func NewBenchMark(deps *options.BenchMarkOptions) (*BenchMark, error) {
 	if deps.UseKubeConfig {
 		proxyConfigFile = os.Getenv("HOME") + "/.kube/edge-proxy.kubeconfig"
 	}
-	if err := util.CreateProxyKubeConfigFile(proxyConfigFile); err != nil {
+	if deps.ProxyKubeConfig != "" {
+		proxyConfigFile = deps.ProxyKubeConfig
+	} else if err := util.CreateProxyKubeConfigFile(proxyConfigFile); err != nil {
 		klog.Errorf("Create edge-proxy kubeconfigfile %s error %v", proxyConfigFile, err)
 		return nil, err
 	}
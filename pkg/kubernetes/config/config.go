@@ -30,8 +30,13 @@ func InitClient(usekubeconfig bool) (*kubernetes.Clientset, error) {
 
 //GetRestConf get rest config from kubeconfig file
 func GetRestConf() (*rest.Config, error) {
+	return GetRestConfFromFile(kubeConfigPath)
+}
+
+// GetRestConfFromFile get rest config from the specified kubeconfig file
+func GetRestConfFromFile(path string) (*rest.Config, error) {
 	// 读 kubeconfig 文件
-	kubeconfig, err := ioutil.ReadFile(kubeConfigPath)
+	kubeconfig, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -14,11 +14,16 @@ import (
 
 // CheckClusterIsHealthyByGet check apiserver is healthy or not use http client skip verify
 func CheckClusterIsHealthyByGet(url string) bool {
+	return CheckClusterIsHealthyByGetWithTimeout(url, 3*time.Second)
+}
+
+// CheckClusterIsHealthyByGetWithTimeout check apiserver is healthy or not in timeout
+func CheckClusterIsHealthyByGetWithTimeout(url string, timeout time.Duration) bool {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
 	client := &http.Client{
-		Timeout:   3 * time.Second,
+		Timeout:   timeout,
 		Transport: tr,
 	}
 
@@ -389,3 +389,8 @@ func IsSchemeResource(gvr schema.GroupVersionResource) bool {
 
 	return false
 }
+
+// KindFor is used to get the built-in GroupVersionKind of gvr
+func KindFor(gvr schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	return unsafeSchemeRESTMapper.KindFor(gvr)
+}
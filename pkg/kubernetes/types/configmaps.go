@@ -2,17 +2,24 @@ package types
 
 //ConfigMapList like v1.ConfigMapList compress some fields
 type ConfigMapList struct {
-	Kind       string `json:"kind"`
-	APIVersion string `json:"apiVersion"`
-	//Metadata   Metadata `json:"metadata"`
-	Items []ConfigMap `json:"items"`
+	Kind       string      `json:"kind"`
+	APIVersion string      `json:"apiVersion"`
+	Metadata   ListMeta    `json:"metadata"`
+	Items      []ConfigMap `json:"items"`
+}
+
+// ListMeta like metav1.ListMeta, resourceVersion is kept, so clients can watch from it,
+// continue is kept, so a page of list can be told apart from a full list
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Continue        string `json:"continue,omitempty"`
 }
 
 type Metadata struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
 	//UID               string          `json:"uid"`
-	//ResourceVersion   string          `json:"resourceVersion"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
 	//CreationTimestamp time.Time       `json:"creationTimestamp"`
 	//Labels map[string]string `json:"labels"`
 	//ManagedFields     []ManagedFields `json:"managedFields"`
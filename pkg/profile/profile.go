@@ -4,22 +4,22 @@ import (
 	"net/http"
 	"net/http/pprof"
 
-	"k8s.io/klog/v2"
-
 	"github.com/gorilla/mux"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 )
 
 // Install adds the Profiling webservice to the given mux.
 func Install(c *mux.Router) {
 	c.HandleFunc("/debug/pprof/profile", func(rw http.ResponseWriter, req *http.Request) {
-		klog.Infof("enter pprof profile: %v", req.URL.String())
+		logs.For(logs.Server).V(2).InfoS("Serve pprof profile", "uri", req.URL.RequestURI())
 		pprof.Profile(rw, req)
 	})
 	c.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	c.HandleFunc("/debug/pprof/trace", pprof.Trace)
 	c.HandleFunc("/debug/pprof", redirectTo("/debug/pprof/"))
 	c.PathPrefix("/debug/pprof/").HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		klog.Infof("enter pprof, %v", req.URL.String())
+		logs.For(logs.Server).V(4).InfoS("Serve pprof", "uri", req.URL.RequestURI())
 		pprof.Index(rw, req)
 	})
 }
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/audit"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
+)
+
+// newAuditMiddleware record requests to audit log when audit-log-path is set,
+// metadata of all requests are recorded if audit-policy-file is not set.
+func newAuditMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	if ctx.Cfg.AuditLogPath == "" {
+		return nil, nil
+	}
+	policy := audit.DefaultPolicy()
+	if ctx.Cfg.AuditPolicyFile != "" {
+		var err error
+		if policy, err = audit.LoadPolicy(ctx.Cfg.AuditPolicyFile); err != nil {
+			return nil, err
+		}
+	}
+	backend, err := audit.NewFileBackend(ctx.Cfg.AuditLogPath, ctx.Cfg.AuditLogMaxSize, ctx.Cfg.AuditLogMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return func(handler http.Handler) http.Handler {
+		return withAudit(handler, ctx.Classifier, policy, backend)
+	}, nil
+}
+
+// withAudit record an audit event after response is completed, so source, status and size are known
+func withAudit(handler http.Handler, classifier *util.RequestClassifier, policy *audit.Policy, backend audit.Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// request info is needed by event, it's reused by the inner requestinfo middleware
+		req, err := classifier.Classify(req)
+		if err != nil {
+			util.WriteErrStatus(w, err)
+			return
+		}
+		req = req.WithContext(util.WithServingSourceRecorder(req.Context()))
+
+		start := time.Now()
+		rw := newStatusRecorder(w)
+		handler.ServeHTTP(rw, req)
+
+		e := newAuditEvent(req)
+		e.Timestamp = start
+		e.Status = rw.status
+		e.Bytes = rw.bytes
+		e.LatencyMillis = float64(time.Since(start).Microseconds()) / 1000
+		if policy.LevelOf(e) == audit.LevelNone {
+			return
+		}
+		if err := backend.Write(e); err != nil {
+			klog.Errorf("could not write audit event of %s, %v", req.URL.Path, err)
+		}
+	})
+}
+
+// newAuditEvent fill metadata of req into an event
+func newAuditEvent(req *http.Request) *audit.Event {
+	ctx := req.Context()
+	e := &audit.Event{Verb: strings.ToLower(req.Method)}
+	if info, ok := apirequest.RequestInfoFrom(ctx); ok {
+		e.Verb = info.Verb
+		if info.IsResourceRequest {
+			e.APIGroup, e.Resource, e.Subresource = info.APIGroup, info.Resource, info.Subresource
+			e.Namespace, e.Name = info.Namespace, info.Name
+		} else {
+			e.RequestURI = req.URL.RequestURI()
+		}
+	}
+	if class, ok := util.RequestClassFrom(ctx); ok {
+		e.Component = class.Component
+	}
+	if comp, ok := util.ClientComponentFrom(ctx); ok {
+		e.Component = comp
+	}
+	if u, ok := apirequest.UserFrom(ctx); ok {
+		e.User = u.GetName()
+	}
+	e.Source, _ = util.ServingSourceFrom(ctx)
+	e.RequestID, _ = logs.RequestIDFrom(ctx)
+	return e
+}
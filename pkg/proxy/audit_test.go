@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/audit"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+type fakeAuditBackend struct {
+	events []*audit.Event
+}
+
+func (b *fakeAuditBackend) Write(e *audit.Event) error {
+	b.events = append(b.events, e)
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	backend := &fakeAuditBackend{}
+	final := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		util.SetServingSource(req.Context(), util.SourceLocal)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+	})
+	handler := withAudit(final, util.NewRequestClassifier(NewRequestInfoResolver()), audit.DefaultPolicy(), backend)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods", nil)
+	req.Header.Set("User-Agent", "kubelet/v1.22.3")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(backend.events) != 1 {
+		t.Fatalf("expect 1 audit event, got %d", len(backend.events))
+	}
+	e := backend.events[0]
+	if e.Component != "kubelet" || e.Verb != "list" || e.Resource != "pods" || e.Namespace != "default" ||
+		e.Source != util.SourceLocal || e.Status != http.StatusOK || e.Bytes != 5 {
+		t.Errorf("unexpected audit event %+v", e)
+	}
+}
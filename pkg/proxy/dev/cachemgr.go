@@ -1,26 +1,54 @@
 package dev
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"code.aliyun.com/openyurt/edge-proxy/pkg/kubernetes/serializer"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/kubernetes/types"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/tracing"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 
 	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	json "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 )
 
+// cacheLog logger of cache reads and writes
+var cacheLog = logs.For(logs.Cache)
+
 //CacheMgr cache for list resp.Body
 type CacheMgr struct {
+	// writeLock serialize local writes to cached list
+	writeLock sync.Mutex
 	//storage disk cache manager for consistency list
 	storage storage.Store
+	// memLock protect memdata and memTime
+	memLock sync.RWMutex
 	//memdata memory cache for list labelSelector result
 	memdata map[string][]byte
+	// memTime write time of memdata
+	memTime map[string]time.Time
 }
 
 // NewCacheMgr create a cachemgr
@@ -28,14 +56,24 @@ func NewCacheMgr(s storage.Store) *CacheMgr {
 	return &CacheMgr{
 		storage: s,
 		memdata: make(map[string][]byte),
+		memTime: make(map[string]time.Time),
 	}
 }
 
+// setMem cache data in memory with key
+func (c *CacheMgr) setMem(key string, data []byte) {
+	c.memLock.Lock()
+	defer c.memLock.Unlock()
+	c.memdata[key] = data
+	c.memTime[key] = time.Now()
+}
+
 //CacheResponseMemNew handle pod and configmaps mem cache
+// comp: client component of request
 // info: req inject requestInfo
 // prc: is a readCloser
 // labelType: filter resource label type or generate unique key for cache
-func (c *CacheMgr) CacheResponseMemNew(info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
+func (c *CacheMgr) CacheResponseMemNew(comp string, info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
 
 	var configmaps types.ConfigMapList
 	err := json.NewDecoder(prc).Decode(&configmaps)
@@ -43,13 +81,17 @@ func (c *CacheMgr) CacheResponseMemNew(info *apirequest.RequestInfo, prc io.Read
 		klog.Errorf("%s decode err: %v", info.Resource, err)
 		return err
 	}
+	if configmaps.Metadata.Continue != "" {
+		cacheLog.V(4).InfoS("Skip caching a page of list", "resource", info.Resource)
+		return nil
+	}
 
 	data, err := json.Marshal(configmaps)
 	if err != nil {
 		klog.Errorf("%s marshal err: %v", info.Resource, err)
 		return err
 	}
-	key := KeyFunc(info.Resource, info.Namespace, labelType)
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
 	//if err = c.storage.Create(key, marshalBytes); err != nil {
 	// klog.Errorf("storage create err: %v", err)
 	// return err
@@ -57,16 +99,16 @@ func (c *CacheMgr) CacheResponseMemNew(info *apirequest.RequestInfo, prc io.Read
 	//klog.Infof("%s storage create ok", info.Resource)
 
 	//data := p.Bytes()
-	c.memdata[key] = data
+	c.setMem(key, data)
 
-	klog.Infof("%s memdata create ok, data.len: %v, data.cap: %v", info.Resource, len(data), cap(data))
+	cacheLog.V(4).InfoS("Cache list in memory", "resource", info.Resource, "key", key, "len", len(data), "cap", cap(data))
 
 	return nil
 }
 
 // Deprecated: CacheResponseMem cache resourceusage list data
-func (c *CacheMgr) CacheResponseMem(info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
-	key := KeyFunc(info.Resource, info.Namespace, labelType)
+func (c *CacheMgr) CacheResponseMem(comp string, info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
 
 	//p := new(bytes.Buffer)
 	//p := bytes.NewBuffer(make([]byte, 0, 100*1024)) // data.len: 1123875 线上测评数据
@@ -79,15 +121,15 @@ func (c *CacheMgr) CacheResponseMem(info *apirequest.RequestInfo, prc io.ReadClo
 	}
 
 	//data := p.Bytes()
-	c.memdata[key] = data
+	c.setMem(key, data)
 
-	klog.Infof("%s memdata create ok, data.len: %v, data.cap: %v", info.Resource, len(data), cap(data))
+	cacheLog.V(4).InfoS("Cache list in memory", "resource", info.Resource, "key", key, "len", len(data), "cap", cap(data))
 
 	return nil
 }
 
 //CacheResponse cache consistency list data
-func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
+func (c *CacheMgr) CacheResponse(comp string, info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
 	switch info.Resource {
 	case "pods":
 		var podList v1.PodList
@@ -96,6 +138,10 @@ func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser
 			klog.Errorf("%s decode err: %v", info.Resource, err)
 			return err
 		}
+		if podList.Continue != "" {
+			cacheLog.V(4).InfoS("Skip caching a page of list", "resource", info.Resource)
+			return nil
+		}
 		var items []v1.Pod
 		for i := 0; i < len(podList.Items); i++ {
 			// filter label type
@@ -110,12 +156,13 @@ func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser
 			klog.Errorf("%s marshal err: %v", info.Resource, err)
 			return err
 		}
-		key := KeyFunc(info.Resource, info.Namespace, labelType)
-		if err = c.storage.Create(key, marshalBytes); err != nil {
+		key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+		stored, err := c.storeList(key, podList.ResourceVersion, marshalBytes)
+		if err != nil {
 			klog.Errorf("%s storage create err: %v", info.Resource, err)
 			return err
 		}
-		klog.Infof("%s storage create ok", info.Resource)
+		cacheLog.V(4).InfoS("Cache list in storage", "resource", info.Resource, "key", key, "items", len(items), "stored", stored)
 	case "configmaps":
 		var configmaps v1.ConfigMapList
 		err := json.NewDecoder(prc).Decode(&configmaps)
@@ -123,6 +170,10 @@ func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser
 			klog.Errorf("%s decode err: %v", info.Resource, err)
 			return err
 		}
+		if configmaps.Continue != "" {
+			cacheLog.V(4).InfoS("Skip caching a page of list", "resource", info.Resource)
+			return nil
+		}
 		var items []v1.ConfigMap
 		for i := 0; i < len(configmaps.Items); i++ {
 			// filter label type
@@ -137,12 +188,13 @@ func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser
 			klog.Errorf("%s marshal err: %v", info.Resource, err)
 			return err
 		}
-		key := KeyFunc(info.Resource, info.Namespace, labelType)
-		if err = c.storage.Create(key, marshalBytes); err != nil {
+		key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+		stored, err := c.storeList(key, configmaps.ResourceVersion, marshalBytes)
+		if err != nil {
 			klog.Errorf("storage create err: %v", err)
 			return err
 		}
-		klog.Infof("%s storage create ok", info.Resource)
+		cacheLog.V(4).InfoS("Cache list in storage", "resource", info.Resource, "key", key, "items", len(items), "stored", stored)
 	default:
 		return fmt.Errorf("err resource type: %s", info.Resource)
 	}
@@ -150,21 +202,472 @@ func (c *CacheMgr) CacheResponse(info *apirequest.RequestInfo, prc io.ReadCloser
 	return nil
 }
 
+// storeList write a list to storage, it's skipped when the cached list is newer,
+// e.g. events of a watch which is started after the list are applied to cache already.
+func (c *CacheMgr) storeList(key, rv string, data []byte) (bool, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if cached, err := c.storage.Get(key); err == nil {
+		cachedRV, err1 := strconv.ParseUint(listResourceVersion(cached), 10, 64)
+		newRV, err2 := strconv.ParseUint(rv, 10, 64)
+		if err1 == nil && err2 == nil && cachedRV > newRV {
+			return false, nil
+		}
+	}
+	return true, c.storage.Create(key, data)
+}
+
+// UpdateCachedObject apply a local write to cached consistency list, it's used when remote server is unhealthy
+// body: request body, it's the patch for patch verb
+// contentType: patch type for patch verb
+// commit: called after the write is validated against cache and before cache is persisted, cache is not changed if it fails
+// the object after write is returned, it's nil for delete
+func (c *CacheMgr) UpdateCachedObject(comp string, info *apirequest.RequestInfo, body []byte, contentType, labelType string, commit func() error) ([]byte, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+	data, err := c.storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var list cachedList
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode cached list err: %w", err)
+	}
+
+	name := info.Name
+	if info.Verb == "create" {
+		var item cachedItem
+		if err = json.Unmarshal(body, &item); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("decode %s err: %v", info.Resource, err))
+		}
+		name = item.Metadata.Name
+	}
+	gr := schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+
+	idx := -1
+	for i := range list.Items {
+		var item cachedItem
+		if err = json.Unmarshal(list.Items[i], &item); err != nil {
+			return nil, fmt.Errorf("decode cached item err: %w", err)
+		}
+		if item.Metadata.Name == name {
+			idx = i
+			break
+		}
+	}
+
+	var obj []byte
+	switch info.Verb {
+	case "create":
+		if idx >= 0 {
+			return nil, apierrors.NewAlreadyExists(gr, name)
+		}
+		obj = body
+	case "update":
+		if idx < 0 {
+			return nil, apierrors.NewNotFound(gr, name)
+		}
+		obj = body
+	case "patch":
+		if idx < 0 {
+			return nil, apierrors.NewNotFound(gr, name)
+		}
+		obj, err = applyPatch(info, list.Items[idx], body, contentType)
+		if err != nil {
+			return nil, err
+		}
+	case "delete":
+		if idx < 0 {
+			return nil, apierrors.NewNotFound(gr, name)
+		}
+	default:
+		return nil, fmt.Errorf("err verb for local write: %s", info.Verb)
+	}
+
+	// keep the same filter with CacheResponse
+	keep := false
+	if obj != nil {
+		var item cachedItem
+		if err = json.Unmarshal(obj, &item); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("decode %s err: %v", info.Resource, err))
+		}
+		keep = item.Metadata.Labels["type"] == labelType
+	}
+	switch {
+	case keep && idx >= 0:
+		list.Items[idx] = obj
+	case keep:
+		list.Items = append(list.Items, obj)
+	case idx >= 0:
+		list.Items = append(list.Items[:idx], list.Items[idx+1:]...)
+	}
+
+	newData, err := json.Marshal(list)
+	if err != nil {
+		klog.Errorf("%s marshal err: %v", info.Resource, err)
+		return nil, err
+	}
+	if commit != nil {
+		if err = commit(); err != nil {
+			return nil, err
+		}
+	}
+	if err = c.storage.Create(key, newData); err != nil {
+		klog.Errorf("%s storage create err: %v", info.Resource, err)
+		return nil, err
+	}
+	cacheLog.V(2).InfoS("Write object to cache locally", "verb", info.Verb, "resource", info.Resource, "name", name)
+
+	return obj, nil
+}
+
+// ResourceVersion get resourceVersion of cached consistency list, clients can list or watch from it
+func (c *CacheMgr) ResourceVersion(ctx context.Context, comp string, info *apirequest.RequestInfo, labelType string) (string, error) {
+	data, err := c.QueryCache(ctx, comp, info, labelType)
+	if err != nil {
+		return "", err
+	}
+	var list struct {
+		Metadata metav1.ListMeta `json:"metadata"`
+	}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return "", fmt.Errorf("decode cached list err: %w", err)
+	}
+	return list.Metadata.ResourceVersion, nil
+}
+
+// CacheWatchEvents apply events of a watch response to cached consistency list until prc is closed,
+// events are skipped if the list is not cached.
+func (c *CacheMgr) CacheWatchEvents(comp string, info *apirequest.RequestInfo, prc io.ReadCloser, labelType string) error {
+	decoder := json.NewDecoder(prc)
+	for {
+		var ev watchEvent
+		if err := decoder.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if ev.Type == "ERROR" {
+			continue
+		}
+		err := retryOnAccessConflict(func() error {
+			return c.ApplyWatchEvent(comp, info, ev.Type, ev.Object, labelType)
+		})
+		switch {
+		case err == nil, errors.Is(err, storage.ErrStorageNotFound):
+		case errors.Is(err, storage.ErrStorageAccessConflict):
+			// cached list is still being accessed by others, the event is skipped instead of ending the watch
+			cacheLog.InfoS("Skip a watch event because cache is busy", "resource", info.Resource, "type", ev.Type)
+		default:
+			return err
+		}
+	}
+}
+
+// accessConflictRetries and accessConflictBackoff retry of storage access, storage fails fast
+// when a key is being accessed by others.
+const (
+	accessConflictRetries = 3
+	accessConflictBackoff = 10 * time.Millisecond
+)
+
+// retryOnAccessConflict call fn again when it fails for storage access conflict
+func retryOnAccessConflict(fn func() error) error {
+	err := fn()
+	for i := 0; i < accessConflictRetries && errors.Is(err, storage.ErrStorageAccessConflict); i++ {
+		time.Sleep(accessConflictBackoff)
+		err = fn()
+	}
+	return err
+}
+
+// cachedEvent a watch event which is decoded for cache
+type cachedEvent struct {
+	Type   string
+	Object []byte
+	item   cachedItem
+	rv     uint64
+}
+
+// newCachedEvent decode object and resourceVersion of a watch event
+func newCachedEvent(eventType string, object []byte) (*cachedEvent, error) {
+	ev := &cachedEvent{Type: eventType, Object: object}
+	if err := json.Unmarshal(object, &ev.item); err != nil {
+		return nil, fmt.Errorf("decode %s event err: %w", eventType, err)
+	}
+	rv, err := strconv.ParseUint(ev.item.Metadata.ResourceVersion, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resourceVersion %q of %s event", ev.item.Metadata.ResourceVersion, eventType)
+	}
+	ev.rv = rv
+	return ev, nil
+}
+
+// ApplyWatchEvent apply a watch event to cached consistency list, and advance resourceVersion of the list
+// to resourceVersion of the event, a BOOKMARK only advances resourceVersion.
+// events which are not newer than the cached list are skipped, they are in cache already.
+func (c *CacheMgr) ApplyWatchEvent(comp string, info *apirequest.RequestInfo, eventType string, object []byte, labelType string) error {
+	ev, err := newCachedEvent(eventType, object)
+	if err != nil {
+		return err
+	}
+	return c.applyWatchEvents(comp, info, []*cachedEvent{ev}, labelType)
+}
+
+// applyWatchEvents apply watch events to cached consistency list in the order of resourceVersion,
+// the list is read and written only once for all of them.
+func (c *CacheMgr) applyWatchEvents(comp string, info *apirequest.RequestInfo, events []*cachedEvent, labelType string) error {
+	sort.Slice(events, func(i, j int) bool { return events[i].rv < events[j].rv })
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+	data, err := c.storage.Get(key)
+	if err != nil {
+		return err
+	}
+	var list cachedList
+	if err = json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("decode cached list err: %w", err)
+	}
+	// resourceVersion of list is 0 when it's invalid, all events are applied
+	listRV, _ := strconv.ParseUint(list.Metadata.ResourceVersion, 10, 64)
+
+	applied := 0
+	for _, ev := range events {
+		if ev.rv <= listRV {
+			continue
+		}
+		switch ev.Type {
+		case "BOOKMARK":
+		case "ADDED", "MODIFIED", "DELETED":
+			idx := -1
+			for i := range list.Items {
+				var item cachedItem
+				if err = json.Unmarshal(list.Items[i], &item); err != nil {
+					return fmt.Errorf("decode cached item err: %w", err)
+				}
+				if item.Metadata.Namespace == ev.item.Metadata.Namespace && item.Metadata.Name == ev.item.Metadata.Name {
+					idx = i
+					break
+				}
+			}
+			// keep the same filter with CacheResponse
+			keep := ev.Type != "DELETED" && ev.item.Metadata.Labels["type"] == labelType
+			switch {
+			case keep && idx >= 0:
+				list.Items[idx] = ev.Object
+			case keep:
+				list.Items = append(list.Items, ev.Object)
+			case idx >= 0:
+				list.Items = append(list.Items[:idx], list.Items[idx+1:]...)
+			}
+		default:
+			return fmt.Errorf("err watch event type: %s", ev.Type)
+		}
+		listRV = ev.rv
+		list.Metadata.ResourceVersion = ev.item.Metadata.ResourceVersion
+		applied++
+		klog.V(5).Infof("%s event of %s/%s is applied to cache, resourceVersion: %d", ev.Type, info.Resource, ev.item.Metadata.Name, ev.rv)
+	}
+	if applied == 0 {
+		return nil
+	}
+
+	newData, err := json.Marshal(list)
+	if err != nil {
+		klog.Errorf("%s marshal err: %v", info.Resource, err)
+		return err
+	}
+	if err = c.storage.Create(key, newData); err != nil {
+		klog.Errorf("%s storage create err: %v", info.Resource, err)
+		return err
+	}
+
+	return nil
+}
+
+// applyPatch apply patch to original object according to patch type
+func applyPatch(info *apirequest.RequestInfo, original, patch []byte, contentType string) ([]byte, error) {
+	switch apitypes.PatchType(contentType) {
+	case apitypes.JSONPatchType:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		return p.Apply(original)
+	case apitypes.MergePatchType:
+		return jsonpatch.MergePatch(original, patch)
+	case apitypes.StrategicMergePatchType:
+		gvk, err := serializer.KindFor(schema.GroupVersionResource{Group: info.APIGroup, Version: info.APIVersion, Resource: info.Resource})
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("strategic merge patch is not supported for %s, %v", info.Resource, err))
+		}
+		dataStruct, err := scheme.Scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		return strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("patch type %q is not supported from cache", contentType))
+	}
+}
+
 //QueryCache query for consistency list data
-func (c *CacheMgr) QueryCache(info *apirequest.RequestInfo, labelType string) ([]byte, error) {
-	key := KeyFunc(info.Resource, info.Namespace, labelType)
-	return c.storage.Get(key)
+func (c *CacheMgr) QueryCache(ctx context.Context, comp string, info *apirequest.RequestInfo, labelType string) ([]byte, error) {
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+	_, span := tracing.Start(ctx, "cache QueryCache", attribute.String("key", key))
+	data, err := c.storage.Get(key)
+	span.SetAttributes(attribute.Bool("hit", err == nil), attribute.Int("size", len(data)))
+	if errors.Is(err, storage.ErrStorageNotFound) {
+		// a miss is not an error of lookup
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return data, err
 }
 
 //QueryCacheMem query for resourceusage list data
-func (c *CacheMgr) QueryCacheMem(resource, ns, labelType string) ([]byte, bool) {
-	key := KeyFunc(resource, ns, labelType)
+func (c *CacheMgr) QueryCacheMem(ctx context.Context, comp, resource, ns, labelType string) ([]byte, bool) {
+	key := KeyFunc(comp, resource, ns, labelType)
+	_, span := tracing.Start(ctx, "cache QueryCacheMem", attribute.String("key", key))
+	c.memLock.RLock()
 	data, ok := c.memdata[key]
+	c.memLock.RUnlock()
+	span.SetAttributes(attribute.Bool("hit", ok), attribute.Int("size", len(data)))
+	span.End()
 	return data, ok
 }
 
+// CacheEntry metadata of a cached list, it's shown by debug endpoints
+type CacheEntry struct {
+	Key string `json:"key"`
+	// Medium disk for consistency lists, memory for resourceusage lists
+	Medium          string    `json:"medium"`
+	Size            int64     `json:"size"`
+	WriteTime       time.Time `json:"writeTime"`
+	Age             string    `json:"age"`
+	ResourceVersion string    `json:"resourceVersion,omitempty"`
+}
+
+// ListCache list lists cached on disk and in memory, sorted by key
+func (c *CacheMgr) ListCache() ([]CacheEntry, error) {
+	keys, err := c.storage.List("")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	entries := make([]CacheEntry, 0, len(keys))
+	for _, k := range keys {
+		if !isCacheKey(k.Key) {
+			continue
+		}
+		entry := CacheEntry{Key: k.Key, Medium: "disk", Size: k.Size, WriteTime: k.ModTime, Age: age(now, k.ModTime)}
+		if data, err := c.storage.Get(k.Key); err == nil {
+			entry.ResourceVersion = listResourceVersion(data)
+		}
+		entries = append(entries, entry)
+	}
+
+	c.memLock.RLock()
+	for key, data := range c.memdata {
+		entries = append(entries, CacheEntry{
+			Key:             key,
+			Medium:          "memory",
+			Size:            int64(len(data)),
+			WriteTime:       c.memTime[key],
+			Age:             age(now, c.memTime[key]),
+			ResourceVersion: listResourceVersion(data),
+		})
+	}
+	c.memLock.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// GetCache get a cached list by key, ErrStorageNotFound is returned if key is not a cached list
+func (c *CacheMgr) GetCache(key string) ([]byte, error) {
+	if !isCacheKey(key) {
+		return nil, storage.ErrStorageNotFound
+	}
+	c.memLock.RLock()
+	data, ok := c.memdata[key]
+	c.memLock.RUnlock()
+	if ok {
+		return data, nil
+	}
+	return c.storage.Get(key)
+}
+
+// EvictCache remove a cached list by key, the list is cached again by the next list from remote server
+func (c *CacheMgr) EvictCache(key string) error {
+	if !isCacheKey(key) {
+		return storage.ErrStorageNotFound
+	}
+	c.memLock.Lock()
+	_, ok := c.memdata[key]
+	delete(c.memdata, key)
+	delete(c.memTime, key)
+	c.memLock.Unlock()
+	if ok {
+		return nil
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.storage.Delete(key)
+}
+
+// isCacheKey returns whether key is generated by KeyFunc, other contents in storage like
+// leases and pki are not cached lists, they are never exposed or removed by key.
+func isCacheKey(key string) bool {
+	if key == "" || key != filepath.Clean(key) || filepath.IsAbs(key) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(key), "/")
+	if len(parts) != 3 && len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == ".." {
+			return false
+		}
+	}
+	if util.IsReservedComponent(parts[0]) {
+		return false
+	}
+	labelType := parts[len(parts)-1]
+	return labelType == consistencyType || labelType == resourceType
+}
+
+// listResourceVersion get resourceVersion of a cached list, it's empty if data is not a list
+func listResourceVersion(data []byte) string {
+	var list struct {
+		Metadata metav1.ListMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return ""
+	}
+	return list.Metadata.ResourceVersion
+}
+
+// age format duration since t, it's truncated to seconds
+func age(now, t time.Time) string {
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
 // KeyFunc generate a key for cache manager
-func KeyFunc(resource, ns, labelType string) string {
-	comp := "bench"
+// comp: client component, cache of different clients are isolated
+func KeyFunc(comp, resource, ns, labelType string) string {
+	if comp == "" {
+		comp = util.DefaultComponent
+	}
 	return filepath.Join(comp, resource, ns, labelType)
 }
@@ -2,11 +2,19 @@ package dev
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 	"testing"
+
+	json "github.com/json-iterator/go"
+	v1 "k8s.io/api/core/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
 )
 
 func TestSlice(t *testing.T) {
@@ -59,3 +67,134 @@ func TestFprintf(t *testing.T) {
 	t.Log(64 << 10)
 	fmt.Fprint(os.Stdout, "hello")
 }
+
+func TestApplyWatchEvent(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCacheMgr(s)
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	if err = s.Create(KeyFunc("kubelet", "pods", "default", consistencyType), newCachedPodList(t, "10", "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	pod := func(name, rv, labelType string) []byte {
+		return []byte(fmt.Sprintf(`{"kind":"Pod","metadata":{"name":%q,"namespace":"default","resourceVersion":%q,"labels":{"type":%q}}}`, name, rv, labelType))
+	}
+
+	events := []struct {
+		eventType string
+		object    []byte
+	}{
+		// older than cache, skipped
+		{"DELETED", pod("a", "9", consistencyType)},
+		{"ADDED", pod("c", "11", consistencyType)},
+		// label is changed, so it's removed from cache
+		{"MODIFIED", pod("b", "12", "other")},
+		{"BOOKMARK", []byte(`{"kind":"Pod","metadata":{"resourceVersion":"15"}}`)},
+	}
+	for _, ev := range events {
+		if err = c.ApplyWatchEvent("kubelet", info, ev.eventType, ev.object, consistencyType); err != nil {
+			t.Fatalf("apply %s event err: %v", ev.eventType, err)
+		}
+	}
+
+	rv, err := c.ResourceVersion(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil || rv != "15" {
+		t.Errorf("expect resourceVersion 15, got %q, %v", rv, err)
+	}
+	data, err := c.QueryCache(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list v1.PodList
+	if err = json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Name != "a" || list.Items[1].Name != "c" {
+		t.Errorf("expect pods a and c in cache, got %v", list.Items)
+	}
+
+	// list which is older than the cached one does not overwrite it
+	listInfo := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	if err = c.CacheResponse("kubelet", listInfo, io.NopCloser(bytes.NewReader(newCachedPodList(t, "12", "x"))), consistencyType); err != nil {
+		t.Fatal(err)
+	}
+	rv, err = c.ResourceVersion(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil || rv != "15" {
+		t.Errorf("expect resourceVersion 15 after an older list, got %q, %v", rv, err)
+	}
+}
+
+func TestListEvictCache(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCacheMgr(s)
+	diskKey := KeyFunc("kubelet", "pods", "default", consistencyType)
+	memKey := KeyFunc("kubelet", "configmaps", "default", resourceType)
+	if err = s.Create(diskKey, newCachedPodList(t, "10", "a")); err != nil {
+		t.Fatal(err)
+	}
+	// not a cached list, it's never exposed
+	if err = s.Create("leases/kube-node-lease/node-a", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	c.setMem(memKey, []byte(`{"metadata":{"resourceVersion":"20"},"items":[]}`))
+
+	entries, err := c.ListCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expect 2 cached lists, got %v", entries)
+	}
+	if e := entries[0]; e.Key != memKey || e.Medium != "memory" || e.ResourceVersion != "20" {
+		t.Errorf("unexpected memory entry %+v", e)
+	}
+	if e := entries[1]; e.Key != diskKey || e.Medium != "disk" || e.ResourceVersion != "10" || e.Size == 0 {
+		t.Errorf("unexpected disk entry %+v", e)
+	}
+
+	if _, err = c.GetCache("leases/kube-node-lease/node-a"); err != storage.ErrStorageNotFound {
+		t.Errorf("expect lease is not exposed, got %v", err)
+	}
+	if err = c.EvictCache("../" + diskKey); err != storage.ErrStorageNotFound {
+		t.Errorf("expect invalid key is rejected, got %v", err)
+	}
+	for _, key := range []string{diskKey, memKey} {
+		if err = c.EvictCache(key); err != nil {
+			t.Fatalf("evict %s err: %v", key, err)
+		}
+		if _, err = c.GetCache(key); err != storage.ErrStorageNotFound {
+			t.Errorf("expect %s is evicted, got %v", key, err)
+		}
+	}
+}
+
+func TestCacheIsolatedByComponent(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCacheMgr(s)
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	if KeyFunc("kubelet", "pods", "default", consistencyType) == KeyFunc("kube-proxy", "pods", "default", consistencyType) {
+		t.Fatalf("expect keys of different components are different")
+	}
+	if KeyFunc("", "pods", "default", consistencyType) != KeyFunc(util.DefaultComponent, "pods", "default", consistencyType) {
+		t.Errorf("expect empty component is the default one")
+	}
+
+	pods := io.NopCloser(bytes.NewReader(newCachedPodList(t, "10", "a")))
+	if err = c.CacheResponse("kubelet", info, pods, consistencyType); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.QueryCache(context.TODO(), "kubelet", info, consistencyType); err != nil {
+		t.Errorf("expect cache of kubelet, got %v", err)
+	}
+	if _, err = c.QueryCache(context.TODO(), "kube-proxy", info, consistencyType); err == nil {
+		t.Errorf("expect cache of kubelet is not visible to kube-proxy")
+	}
+}
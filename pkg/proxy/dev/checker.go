@@ -18,13 +18,42 @@ type checker struct {
 	clusterHealthy bool
 	// lastTime last health status update time
 	lastTime time.Time
+	// settings return interval and timeout of health check, they can be changed at runtime
+	settings HealthCheckSettings
+	// listeners are called after health status is changed
+	listeners []func(healthy bool)
+	// lastCheck time of the last health check
+	lastCheck time.Time
+	// history recent changes of health status, the oldest is dropped when it's full
+	history []HealthEvent
 }
 
+// maxHealthHistory max number of health status changes kept by checker
+const maxHealthHistory = 100
+
+// HealthEvent a change of health status of remote server
+type HealthEvent struct {
+	Healthy bool      `json:"healthy"`
+	Time    time.Time `json:"time"`
+}
+
+// HealthStatus health status and recent changes of it, it's shown by debug endpoints
+type HealthStatus struct {
+	Server    string        `json:"server"`
+	Healthy   bool          `json:"healthy"`
+	LastCheck time.Time     `json:"lastCheck"`
+	History   []HealthEvent `json:"history"`
+}
+
+// HealthCheckSettings is func for fetching interval and timeout of health check
+type HealthCheckSettings func() (time.Duration, time.Duration)
+
 // NewChecker create a checker with remoteServer
-func NewChecker(remoteServer *url.URL) *checker {
+func NewChecker(remoteServer *url.URL, settings HealthCheckSettings) *checker {
 	return &checker{
 		remoteServer: remoteServer,
 		lastTime:     time.Now(),
+		settings:     settings,
 	}
 }
 
@@ -36,10 +65,11 @@ func (c *checker) start(stopCh <-chan struct{}) {
 
 // loop for health check loop
 func (c *checker) loop(stopCh <-chan struct{}) {
-	checkDuration := 10 * time.Second
+	checkDuration, _ := c.settings()
 	timer := time.NewTimer(checkDuration)
 	defer timer.Stop()
 	for {
+		checkDuration, _ = c.settings()
 		timer.Reset(checkDuration)
 		select {
 		case <-stopCh:
@@ -54,7 +84,10 @@ func (c *checker) loop(stopCh <-chan struct{}) {
 
 // check check remote server is healthy or not
 func (c *checker) check() {
-	if !health.CheckClusterIsHealthyByGet(c.remoteServer.String()) {
+	_, timeout := c.settings()
+	healthy := health.CheckClusterIsHealthyByGetWithTimeout(c.remoteServer.String(), timeout)
+	c.record(healthy, time.Now())
+	if !healthy {
 		c.markAsUnhealthy()
 		return
 	}
@@ -62,11 +95,53 @@ func (c *checker) check() {
 	c.markAsHealthy()
 }
 
+// record record result of a health check, an event is added to history if health status is changed
+func (c *checker) record(healthy bool, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.lastCheck = now
+	if n := len(c.history); n != 0 && c.history[n-1].Healthy == healthy {
+		return
+	}
+	if len(c.history) == maxHealthHistory {
+		c.history = append(c.history[:0], c.history[1:]...)
+	}
+	c.history = append(c.history, HealthEvent{Healthy: healthy, Time: now})
+}
+
+// status get health status and a copy of history
+func (c *checker) status() HealthStatus {
+	c.RLock()
+	defer c.RUnlock()
+	return HealthStatus{
+		Server:    c.remoteServer.String(),
+		Healthy:   c.clusterHealthy,
+		LastCheck: c.lastCheck,
+		History:   append([]HealthEvent{}, c.history...),
+	}
+}
+
+// addListener add a listener for changes of health status, it should be added before checker is started
+func (c *checker) addListener(l func(healthy bool)) {
+	c.listeners = append(c.listeners, l)
+}
+
+// notify call listeners with health status
+func (c *checker) notify(healthy bool) {
+	for _, l := range c.listeners {
+		l(healthy)
+	}
+}
+
 // markAsHealthy mark a remote server healthy
 func (c *checker) markAsHealthy() {
+	changed := !c.isHealthy()
 	c.setHealthy(true)
 	now := time.Now()
 	c.lastTime = now
+	if changed {
+		c.notify(true)
+	}
 }
 
 // markAsUnhealthy mark a remote server unhealthy
@@ -81,6 +156,7 @@ func (c *checker) markAsUnhealthy() {
 			c.remoteServer.String(),
 		)
 		c.lastTime = now
+		c.notify(false)
 	}
 }
 
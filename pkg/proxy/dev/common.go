@@ -1,8 +1,11 @@
 package dev
 
 import (
+	"fmt"
+	"io"
 	"strings"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 )
 
@@ -16,6 +19,22 @@ const (
 	resourceType     = "resourceusage"
 )
 
+// maxRequestBodyBytes is the max size of request body which is accepted locally, same as kube-apiserver
+const maxRequestBodyBytes = 3 * 1024 * 1024
+
+// readRequestBody read body of request which is served locally, body larger than maxRequestBodyBytes is rejected
+// instead of being truncated.
+func readRequestBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxRequestBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRequestBodyBytes {
+		return nil, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf("limit is %d", maxRequestBodyBytes))
+	}
+	return data, nil
+}
+
 // checkLabel check request labelSelector include label or not
 func checkLabel(info *apirequest.RequestInfo, selector string, label string) bool {
 	if info.IsResourceRequest && info.Verb == "list" &&
@@ -26,3 +45,11 @@ func checkLabel(info *apirequest.RequestInfo, selector string, label string) boo
 
 	return false
 }
+
+// isConsistencyWatch check request is a watch of consistency pods or configmaps,
+// events of it are applied to the cached consistency list.
+func isConsistencyWatch(info *apirequest.RequestInfo, selector string) bool {
+	return info.IsResourceRequest && info.Verb == "watch" && info.Name == "" && info.Namespace != "" &&
+		(info.Resource == "pods" || info.Resource == "configmaps") &&
+		(selector == "" || strings.Contains(selector, consistencyLabel))
+}
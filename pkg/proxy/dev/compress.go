@@ -0,0 +1,48 @@
+package dev
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+// acceptsGzip check client accepts gzip response or not
+func acceptsGzip(h http.Header) bool {
+	return strings.Contains(h.Get("Accept-Encoding"), "gzip")
+}
+
+// decompressResponse gunzip body of resp for clients which don't accept gzip, like http.Transport does
+func decompressResponse(resp *http.Response) *http.Response {
+	info, _ := apirequest.RequestInfoFrom(resp.Request.Context())
+	body, ok := util.NewGZipReaderCloser(resp.Header, resp.Body, info, "remote-proxy")
+	if !ok {
+		return resp
+	}
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp
+}
+
+// gzipBody compress rc in memory, rc is closed after it's read
+func gzipBody(rc io.ReadCloser) (int, io.ReadCloser, error) {
+	defer rc.Close()
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if _, err := io.Copy(zw, rc); err != nil {
+		return 0, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, nil, err
+	}
+	return buf.Len(), io.NopCloser(buf), nil
+}
@@ -0,0 +1,66 @@
+package dev
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteProxyCompression(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept-Encoding") != "gzip" {
+			w.Write([]byte("identity"))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("compressed"))
+		zw.Close()
+	}))
+	defer upstream.Close()
+
+	roundTrip := func(compression bool, acceptEncoding string) (*http.Response, string) {
+		rp := &RemoteProxy{currentTransport: &http.Transport{DisableCompression: true}, compression: compression}
+		req := httptest.NewRequest(http.MethodGet, upstream.URL, nil)
+		req.RequestURI = ""
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := rp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("request of client should not be modified")
+		}
+		return resp, string(data)
+	}
+
+	// client doesn't accept gzip, response is decompressed by proxy
+	resp, body := roundTrip(true, "")
+	if body != "compressed" || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("expect decompressed response, got %q, %q", body, resp.Header.Get("Content-Encoding"))
+	}
+
+	// client accepts gzip, response is passed as it is
+	resp, _ = roundTrip(true, "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("expect gzip response for client which accepts gzip")
+	}
+
+	// compression is disabled, Accept-Encoding of client is passed as it is
+	resp, _ = roundTrip(false, "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("expect gzip response for client which accepts gzip when compression is disabled")
+	}
+	if _, body = roundTrip(false, ""); body != "identity" {
+		t.Errorf("expect identity response when compression is disabled, got %q", body)
+	}
+}
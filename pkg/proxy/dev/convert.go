@@ -0,0 +1,191 @@
+package dev
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// define list representation which can be asked by Accept header
+const (
+	asTable                     = "Table"
+	asPartialObjectMetadataList = "PartialObjectMetadataList"
+	asPartialObjectMetadata     = "PartialObjectMetadata"
+)
+
+// swaggerMetadataDescriptions descriptions for default table columns
+var swaggerMetadataDescriptions = metav1.ObjectMeta{}.SwaggerDoc()
+
+// listTarget list representation negotiated from Accept header
+// as: Table or PartialObjectMetadataList
+// version: v1 or v1beta1 of meta.k8s.io
+type listTarget struct {
+	as      string
+	version string
+}
+
+// cachedItem only metadata of a cached list item is needed for conversion
+type cachedItem struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+}
+
+// contentType media type of response in target representation
+func (t *listTarget) contentType() string {
+	return fmt.Sprintf("application/json;as=%s;g=%s;v=%s", t.as, metav1.GroupName, t.version)
+}
+
+// negotiateListTarget parse Accept header like "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+// nil will be returned if cached list should be returned as is.
+func negotiateListTarget(accept string) (*listTarget, error) {
+	return negotiateTarget(accept, asTable, asPartialObjectMetadataList)
+}
+
+// negotiateWatchTarget parse Accept header of watch, objects of events can be converted into
+// PartialObjectMetadata for metadata informers, nil will be returned if cached objects should be sent as is.
+func negotiateWatchTarget(accept string) (*listTarget, error) {
+	return negotiateTarget(accept, asPartialObjectMetadata)
+}
+
+// negotiateTarget parse Accept header, supported: representations which can be converted from cache
+func negotiateTarget(accept string, supported ...string) (*listTarget, error) {
+	if accept == "" {
+		return nil, nil
+	}
+
+	var asked []string
+	for _, clause := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(clause))
+		if err != nil {
+			continue
+		}
+		as := params["as"]
+		if as == "" {
+			if mediaType == "application/json" || mediaType == "*/*" || mediaType == "application/*" {
+				// client accepts plain list
+				return nil, nil
+			}
+			continue
+		}
+		asked = append(asked, as)
+		if mediaType != "application/json" || params["g"] != metav1.GroupName {
+			continue
+		}
+		if params["v"] != "v1" && params["v"] != "v1beta1" {
+			continue
+		}
+		for _, s := range supported {
+			if as == s {
+				return &listTarget{as: as, version: params["v"]}, nil
+			}
+		}
+	}
+
+	if len(asked) != 0 {
+		mediaTypes := []string{"application/json"}
+		for _, s := range supported {
+			mediaTypes = append(mediaTypes, fmt.Sprintf("application/json;as=%s;g=%s", s, metav1.GroupName))
+		}
+		return nil, &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status: metav1.StatusFailure,
+			Code:   http.StatusNotAcceptable,
+			Reason: metav1.StatusReason("NotAcceptable"),
+			Message: fmt.Sprintf("only the following media types are accepted from cache: %s, asked: %v",
+				strings.Join(mediaTypes, ", "), asked),
+		}}
+	}
+	return nil, nil
+}
+
+// isPlainJSON check response is a json object of resource, like PodList instead of Table,
+// only plain json responses are cached, because cache is converted into other representations when it's served.
+func isPlainJSON(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json" && params["as"] == ""
+}
+
+// toPartialObjectMetadata convert a cached object into PartialObjectMetadata of meta.k8s.io/version
+func toPartialObjectMetadata(raw []byte, version string) ([]byte, error) {
+	var item cachedItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, fmt.Errorf("decode cached item err: %w", err)
+	}
+	return json.Marshal(&metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{Kind: asPartialObjectMetadata, APIVersion: metav1.GroupName + "/" + version},
+		ObjectMeta: item.Metadata,
+	})
+}
+
+// convertList convert cached list data into Table or PartialObjectMetadataList
+// query: request query, includeObject is used for Table
+func convertList(data []byte, target *listTarget, query url.Values) ([]byte, error) {
+	var list cachedList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode cached list err: %w", err)
+	}
+
+	apiVersion := metav1.GroupName + "/" + target.version
+	items := make([]metav1.PartialObjectMetadata, 0, len(list.Items))
+	for i := range list.Items {
+		var item cachedItem
+		if err := json.Unmarshal(list.Items[i], &item); err != nil {
+			return nil, fmt.Errorf("decode cached item err: %w", err)
+		}
+		items = append(items, metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{Kind: "PartialObjectMetadata", APIVersion: apiVersion},
+			ObjectMeta: item.Metadata,
+		})
+	}
+
+	switch target.as {
+	case asPartialObjectMetadataList:
+		return json.Marshal(&metav1.PartialObjectMetadataList{
+			TypeMeta: metav1.TypeMeta{Kind: asPartialObjectMetadataList, APIVersion: apiVersion},
+			ListMeta: list.Metadata,
+			Items:    items,
+		})
+	case asTable:
+		includeObject := metav1.IncludeObjectPolicy(query.Get("includeObject"))
+		table := &metav1.Table{
+			TypeMeta: metav1.TypeMeta{Kind: asTable, APIVersion: apiVersion},
+			ListMeta: list.Metadata,
+			// same columns as default table convertor of kube-apiserver
+			ColumnDefinitions: []metav1.TableColumnDefinition{
+				{Name: "Name", Type: "string", Format: "name", Description: swaggerMetadataDescriptions["name"]},
+				{Name: "Created At", Type: "date", Description: swaggerMetadataDescriptions["creationTimestamp"]},
+			},
+			Rows: make([]metav1.TableRow, 0, len(items)),
+		}
+		for i := range items {
+			row := metav1.TableRow{
+				Cells: []interface{}{items[i].Name, items[i].CreationTimestamp.Time.UTC().Format(time.RFC3339)},
+			}
+			switch includeObject {
+			case metav1.IncludeNone:
+			case metav1.IncludeObject:
+				row.Object = runtime.RawExtension{Raw: list.Items[i]}
+			case metav1.IncludeMetadata, "":
+				raw, err := json.Marshal(&items[i])
+				if err != nil {
+					return nil, err
+				}
+				row.Object = runtime.RawExtension{Raw: raw}
+			default:
+				return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid includeObject: %q", includeObject))
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		return json.Marshal(table)
+	default:
+		klog.Errorf("unknown list target %s, return cached list directly", target.as)
+		return data, nil
+	}
+}
@@ -0,0 +1,90 @@
+package dev
+
+import (
+	"net/url"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNegotiateListTarget(t *testing.T) {
+	tests := []struct {
+		accept string
+		as     string
+		err    bool
+	}{
+		{"", "", false},
+		{"application/json", "", false},
+		{"application/json;as=Table;v=v1;g=meta.k8s.io,application/json;as=Table;v=v1beta1;g=meta.k8s.io,application/json", asTable, false},
+		{"application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1", asPartialObjectMetadataList, false},
+		{"application/vnd.kubernetes.protobuf;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1", "", true},
+		{"application/vnd.kubernetes.protobuf", "", false},
+	}
+
+	for _, tt := range tests {
+		target, err := negotiateListTarget(tt.accept)
+		if (err != nil) != tt.err {
+			t.Errorf("accept %q, expect err %v, got %v", tt.accept, tt.err, err)
+			continue
+		}
+		as := ""
+		if target != nil {
+			as = target.as
+		}
+		if as != tt.as {
+			t.Errorf("accept %q, expect %q, got %q", tt.accept, tt.as, as)
+		}
+	}
+
+	_, err := negotiateListTarget("application/json;as=Unknown;g=meta.k8s.io;v=v1")
+	if status, ok := err.(apierrors.APIStatus); !ok || status.Status().Code != 406 {
+		t.Errorf("expect not acceptable, got %v", err)
+	}
+}
+
+func TestNegotiateWatchTarget(t *testing.T) {
+	target, err := negotiateWatchTarget("application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1,application/json")
+	if err != nil || target == nil || target.as != asPartialObjectMetadata {
+		t.Errorf("expect PartialObjectMetadata target, got %v, %v", target, err)
+	}
+	if _, err = negotiateWatchTarget("application/json;as=Table;g=meta.k8s.io;v=v1"); err == nil {
+		t.Errorf("expect Table watch is not acceptable")
+	}
+}
+
+func TestConvertList(t *testing.T) {
+	data := newCachedPodList(t, "100", "a", "b")
+
+	res, err := convertList(data, &listTarget{as: asTable, version: "v1"}, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table metav1.Table
+	if err = json.Unmarshal(res, &table); err != nil {
+		t.Fatal(err)
+	}
+	if table.Kind != "Table" || table.ResourceVersion != "100" || len(table.ColumnDefinitions) != 2 || len(table.Rows) != 2 {
+		t.Fatalf("unexpected table: %s", string(res))
+	}
+	if table.Rows[0].Cells[0] != "a" {
+		t.Errorf("expect first row name a, got %v", table.Rows[0].Cells[0])
+	}
+	var partial metav1.PartialObjectMetadata
+	if err = json.Unmarshal(table.Rows[1].Object.Raw, &partial); err != nil || partial.Name != "b" {
+		t.Errorf("expect partial object metadata in row, got %s, %v", string(table.Rows[1].Object.Raw), err)
+	}
+
+	res, err = convertList(data, &listTarget{as: asPartialObjectMetadataList, version: "v1"}, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metaList metav1.PartialObjectMetadataList
+	if err = json.Unmarshal(res, &metaList); err != nil {
+		t.Fatal(err)
+	}
+	if metaList.Kind != asPartialObjectMetadataList || len(metaList.Items) != 2 || metaList.Items[0].Namespace != "default" {
+		t.Errorf("unexpected partial object metadata list: %s", string(res))
+	}
+}
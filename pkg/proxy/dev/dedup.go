@@ -0,0 +1,214 @@
+package dev
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+// dedupGroup collapse identical in-flight get/list requests into one upstream call,
+// and the response body is fanned out to all callers.
+type dedupGroup struct {
+	sync.Mutex
+	calls map[string]*dedupCall
+}
+
+// dedupCall an upstream call shared by the leader and waiters
+type dedupCall struct {
+	key string
+	// done is closed when the response is fanned out to waiters
+	done    chan struct{}
+	waiters []*dedupWaiter
+	// refs number of callers waiting for the response, including the leader
+	refs int
+	// cancel cancel the upstream call, it's called when all callers are gone before the response
+	cancel context.CancelFunc
+	err    error
+}
+
+// dedupWaiter a request waiting for the response of leader
+type dedupWaiter struct {
+	req  *http.Request
+	resp *http.Response
+}
+
+func newDedupGroup() *dedupGroup {
+	return &dedupGroup{calls: map[string]*dedupCall{}}
+}
+
+// canDedup only get and list are collapsed, watches are long running and can not be shared
+func canDedup(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	return ok && info.IsResourceRequest && (info.Verb == "get" || info.Verb == "list")
+}
+
+// dedupKey requests with the same path, query, Accept and identity share the same key
+func dedupKey(req *http.Request) string {
+	return hashKey(req.Header, req.URL.String(), req.Header.Get("Accept"), req.Header.Get("Accept-Encoding"))
+}
+
+// hashKey hash parts and identity of h, identity is the Authorization and Impersonate-* headers,
+// key is hashed so credentials are not kept.
+func hashKey(h http.Header, parts ...string) string {
+	hash := sha256.New()
+	write := func(s string) {
+		hash.Write([]byte(s))
+		hash.Write([]byte{0})
+	}
+	for _, part := range parts {
+		write(part)
+	}
+	write(h.Get("Authorization"))
+
+	names := make([]string, 0)
+	for name := range h {
+		if strings.HasPrefix(name, "Impersonate-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		write(name)
+		write(strings.Join(h.Values(name), ","))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// RoundTrip join the in-flight call with the same key, or start a new one by rt.
+func (g *dedupGroup) RoundTrip(req *http.Request, rt http.RoundTripper) (*http.Response, error) {
+	key := dedupKey(req)
+
+	g.Lock()
+	if c, ok := g.calls[key]; ok {
+		w := &dedupWaiter{req: req}
+		c.waiters = append(c.waiters, w)
+		c.refs++
+		g.Unlock()
+		klog.V(5).Infof("request %s joins an in-flight call", req.URL.String())
+		return g.wait(c, w, rt)
+	}
+	// upstream call is shared, so it's not canceled with the leader, but when all callers are gone
+	ctx, cancel := context.WithCancel(detachedContext{req.Context()})
+	c := &dedupCall{key: key, done: make(chan struct{}), refs: 1, cancel: cancel}
+	g.calls[key] = c
+	g.Unlock()
+
+	go func() {
+		select {
+		case <-req.Context().Done():
+			g.leave(c)
+		case <-c.done:
+		}
+	}()
+	resp, err := rt.RoundTrip(req.WithContext(ctx))
+
+	g.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	if err != nil {
+		c.err = err
+	} else if len(c.waiters) != 0 {
+		bodies := util.NewMultiReadCloser(resp.Body, len(c.waiters)+1)
+		for i, w := range c.waiters {
+			w.resp = cloneResponse(resp, w.req, bodies[i+1])
+		}
+		resp.Body = bodies[0]
+	}
+	close(c.done)
+	g.Unlock()
+
+	return resp, err
+}
+
+// wait wait for the response of leader, the waiter leaves the call if its context is done
+func (g *dedupGroup) wait(c *dedupCall, w *dedupWaiter, rt http.RoundTripper) (*http.Response, error) {
+	select {
+	case <-c.done:
+		if c.err != nil {
+			// leader failed, retry by itself, so errors of leader are not propagated
+			klog.V(4).Infof("shared call of %s failed, %v, retry it", w.req.URL.String(), c.err)
+			return rt.RoundTrip(w.req)
+		}
+		return w.resp, nil
+	case <-w.req.Context().Done():
+	}
+
+	g.Lock()
+	select {
+	case <-c.done:
+		// response is fanned out already, close the body, so other callers are not blocked
+		if w.resp != nil {
+			w.resp.Body.Close()
+		}
+	default:
+		for i := range c.waiters {
+			if c.waiters[i] == w {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				break
+			}
+		}
+		g.leaveLocked(c)
+	}
+	g.Unlock()
+	return nil, w.req.Context().Err()
+}
+
+// leave the leader leaves the call when its context is done before the response
+func (g *dedupGroup) leave(c *dedupCall) {
+	g.Lock()
+	defer g.Unlock()
+	select {
+	case <-c.done:
+	default:
+		g.leaveLocked(c)
+	}
+}
+
+// leaveLocked a caller leaves the call, the upstream call is canceled when it's the last one,
+// and new requests with the same key start a new call. it should be called with g.Lock held
+func (g *dedupGroup) leaveLocked(c *dedupCall) {
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	klog.V(5).Infof("all callers of %s are gone, cancel the in-flight call", c.key)
+	c.cancel()
+	if g.calls[c.key] == c {
+		delete(g.calls, c.key)
+	}
+}
+
+// cloneResponse copy resp for req with body
+func cloneResponse(resp *http.Response, req *http.Request, body io.ReadCloser) *http.Response {
+	r := *resp
+	r.Header = resp.Header.Clone()
+	r.Trailer = nil
+	r.Request = req
+	r.Body = body
+	return &r
+}
+
+// detachedContext keep values of parent, but it's never canceled with parent
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,167 @@
+package dev
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type blockingRoundTripper struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (rt *blockingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&rt.calls, 1) == 1 {
+		close(rt.started)
+	}
+	<-rt.release
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"kind":"PodList","items":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+func newListRequest(user string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods?limit=500", nil)
+	req.Header.Set("Impersonate-User", user)
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods", APIVersion: "v1"}
+	return req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+}
+
+func TestDedupGroup(t *testing.T) {
+	g := newDedupGroup()
+	rt := &blockingRoundTripper{started: make(chan struct{}), release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		if i == 1 {
+			// followers join after the leader has started the upstream call
+			<-rt.started
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := g.RoundTrip(newListRequest("alice"), rt)
+			if err != nil {
+				t.Errorf("RoundTrip err: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i] = string(data)
+		}(i)
+	}
+
+	// wait for followers to join
+	key := dedupKey(newListRequest("alice"))
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		g.Lock()
+		n := len(g.calls[key].waiters)
+		g.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Fatalf("followers do not join the call")
+		}
+	}
+	if dedupKey(newListRequest("bob")) == key {
+		t.Errorf("requests of different users should not share a call")
+	}
+
+	close(rt.release)
+	wg.Wait()
+
+	if rt.calls != 1 {
+		t.Errorf("expect 1 upstream call, got %d", rt.calls)
+	}
+	for i, body := range bodies {
+		if body != `{"kind":"PodList","items":[]}` {
+			t.Errorf("caller %d got unexpected body %q", i, body)
+		}
+	}
+	if len(g.calls) != 0 {
+		t.Errorf("call should be removed when it's finished")
+	}
+}
+
+// cancelRoundTripper block until request is canceled
+type cancelRoundTripper struct {
+	started  chan struct{}
+	canceled chan struct{}
+}
+
+func (rt *cancelRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	close(rt.started)
+	<-req.Context().Done()
+	close(rt.canceled)
+	return nil, req.Context().Err()
+}
+
+func TestDedupGroupCancel(t *testing.T) {
+	g := newDedupGroup()
+	rt := &cancelRoundTripper{started: make(chan struct{}), canceled: make(chan struct{})}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := g.RoundTrip(newListRequest("alice").WithContext(leaderCtx), rt)
+		errs <- err
+	}()
+	<-rt.started
+	go func() {
+		_, err := g.RoundTrip(newListRequest("alice").WithContext(waiterCtx), rt)
+		errs <- err
+	}()
+	key := dedupKey(newListRequest("alice"))
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		g.Lock()
+		n := len(g.calls[key].waiters)
+		g.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Fatalf("waiter does not join the call")
+		}
+	}
+
+	// upstream call is kept for the waiter when the leader is gone
+	cancelLeader()
+	select {
+	case <-rt.canceled:
+		t.Fatalf("upstream call should not be canceled when a waiter is left")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancelWaiter()
+	select {
+	case <-rt.canceled:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("upstream call should be canceled when all callers are gone")
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Errorf("expect error for canceled callers")
+		}
+	}
+	g.Lock()
+	defer g.Unlock()
+	if len(g.calls) != 0 {
+		t.Errorf("canceled call should be removed")
+	}
+}
@@ -0,0 +1,93 @@
+package dev
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+)
+
+// eventFlushInterval interval of applying buffered events of shared watches to cache
+const eventFlushInterval = 500 * time.Millisecond
+
+// eventBuffer buffer events of shared watches and apply them to cached lists in background,
+// only the latest event of an object is kept, and a cached list is rewritten once per flush,
+// so the read loop of shared watches is not blocked by writing cache to disk.
+type eventBuffer struct {
+	sync.Mutex
+	cacheMgr *CacheMgr
+	interval time.Duration
+	// writers a flush is tracked as a cache writer, so it's waited when proxy is drained
+	writers *sync.WaitGroup
+	// lists buffered events by key of cached list, a flush is scheduled when it's not empty
+	lists map[string]*bufferedList
+}
+
+// bufferedList events to be applied to a cached list
+type bufferedList struct {
+	comp      string
+	info      *apirequest.RequestInfo
+	labelType string
+	// events latest event by namespace/name of object, "" is for BOOKMARK
+	events map[string]*cachedEvent
+}
+
+func newEventBuffer(cacheMgr *CacheMgr, interval time.Duration, writers *sync.WaitGroup) *eventBuffer {
+	return &eventBuffer{
+		cacheMgr: cacheMgr,
+		interval: interval,
+		writers:  writers,
+		lists:    map[string]*bufferedList{},
+	}
+}
+
+// add buffer an event, it replaces the buffered event of the same object
+func (b *eventBuffer) add(comp string, info *apirequest.RequestInfo, ev *cachedEvent, labelType string) {
+	key := KeyFunc(comp, info.Resource, info.Namespace, labelType)
+
+	b.Lock()
+	defer b.Unlock()
+	if len(b.lists) == 0 {
+		b.writers.Add(1)
+		time.AfterFunc(b.interval, b.flush)
+	}
+	l, ok := b.lists[key]
+	if !ok {
+		l = &bufferedList{comp: comp, info: info, labelType: labelType, events: map[string]*cachedEvent{}}
+		b.lists[key] = l
+	}
+	name := ""
+	if ev.Type != "BOOKMARK" {
+		name = ev.item.Metadata.Namespace + "/" + ev.item.Metadata.Name
+	}
+	if old, ok := l.events[name]; !ok || old.rv < ev.rv {
+		l.events[name] = ev
+	}
+}
+
+// flush apply buffered events to cache
+func (b *eventBuffer) flush() {
+	defer b.writers.Done()
+
+	b.Lock()
+	lists := b.lists
+	b.lists = map[string]*bufferedList{}
+	b.Unlock()
+
+	for _, l := range lists {
+		events := make([]*cachedEvent, 0, len(l.events))
+		for _, ev := range l.events {
+			events = append(events, ev)
+		}
+		err := retryOnAccessConflict(func() error {
+			return b.cacheMgr.applyWatchEvents(l.comp, l.info, events, l.labelType)
+		})
+		if err != nil && !errors.Is(err, storage.ErrStorageNotFound) {
+			klog.Errorf("could not apply %d events of %s to cache, %v", len(events), l.info.Resource, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dev
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+func TestEventBuffer(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCacheMgr(s)
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	if err = s.Create(KeyFunc("kubelet", "pods", "default", consistencyType), newCachedPodList(t, "10", "a")); err != nil {
+		t.Fatal(err)
+	}
+	var writers sync.WaitGroup
+	b := newEventBuffer(c, 100*time.Millisecond, &writers)
+
+	add := func(eventType, name, rv, image string) {
+		object := fmt.Sprintf(`{"kind":"Pod","metadata":{"name":%q,"namespace":"default","resourceVersion":%q,"labels":{"type":"consistency"}},"spec":{"containers":[{"image":%q}]}}`, name, rv, image)
+		ev, err := newCachedEvent(eventType, []byte(object))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.add("kubelet", info, ev, consistencyType)
+	}
+	add("ADDED", "b", "11", "v1")
+	add("MODIFIED", "b", "13", "v3")
+	// an older event of the same object is dropped
+	add("MODIFIED", "b", "12", "v2")
+	add("DELETED", "a", "14", "")
+
+	// events are not applied until they are flushed
+	rv, err := c.ResourceVersion(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil || rv != "10" {
+		t.Errorf("expect resourceVersion 10 before flush, got %q, %v", rv, err)
+	}
+
+	writers.Wait()
+	rv, err = c.ResourceVersion(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil || rv != "14" {
+		t.Errorf("expect resourceVersion 14 after flush, got %q, %v", rv, err)
+	}
+	data, err := c.QueryCache(context.TODO(), "kubelet", info, consistencyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"name":"a"`) || !strings.Contains(string(data), `"image":"v3"`) {
+		t.Errorf("expect only the latest pod b in cache, got %s", data)
+	}
+}
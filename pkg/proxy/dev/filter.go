@@ -2,14 +2,18 @@ package dev
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strings"
 
 	json "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/tracing"
 )
 
 //skipListFilterReadCloser for filter benchmark
@@ -31,10 +35,19 @@ func (s *skipListFilterReadCloser) Close() error {
 }
 
 // NewFilterReadCloser filter prefix for rc
+// ctx: context of request, filtering is traced as a child span of it
 // rc: list filter apiserver resp io.ReadCloser(resp.Body)
 // resource: maybe configmaps/pods
 // prefix: it should be "skip-" in order to pass filter benchmark
-func NewFilterReadCloser(rc io.ReadCloser, resource string, prefix string) (int, io.ReadCloser, error) {
+func NewFilterReadCloser(ctx context.Context, rc io.ReadCloser, resource string, prefix string) (int, io.ReadCloser, error) {
+	_, span := tracing.Start(ctx, "filter NewFilterReadCloser", attribute.String("resource", resource), attribute.String("prefix", prefix))
+	size, frc, err := newFilterReadCloser(rc, resource, prefix)
+	span.SetAttributes(attribute.Int("size", size))
+	tracing.End(span, err)
+	return size, frc, err
+}
+
+func newFilterReadCloser(rc io.ReadCloser, resource string, prefix string) (int, io.ReadCloser, error) {
 	sfrc := &skipListFilterReadCloser{
 		data: new(bytes.Buffer),
 		rc:   rc,
@@ -1,6 +1,7 @@
 package dev
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -10,22 +11,29 @@ import (
 
 	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
-	"k8s.io/apimachinery/pkg/util/sets"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
-	"k8s.io/apiserver/pkg/server"
 )
 
+// devFactoryKey key of devFactory in proxy.MiddlewareContext.Values
+const devFactoryKey = "dev"
+
 func init() {
-	proxy.Register("sample", &devFactory{})
+	proxy.Register("dev", &devFactory{})
+	proxy.RegisterMiddleware("resourcecache", newResourceCacheMiddleware)
 }
 
 type devFactory struct {
-	// for inject request info to http.Request
-	resolver apirequest.RequestInfoResolver
+	// classifier inject request info and request class to http.Request
+	classifier *util.RequestClassifier
 	// remoteProxy reverseProxy for remote server
 	remoteProxy APIServerProxy
+	// remote is the same as remoteProxy, it's used for draining
+	remote *RemoteProxy
 	// localProxy use local proxy when remote server unhealthy
 	localProxy APIServerProxy
+	// writeQueue queue writes when remote server is unhealthy, nil means disabled
+	writeQueue *WriteQueue
 	cfg        *config.EdgeProxyConfiguration
 	// cacheMgr cache manager
 	cacheMgr *CacheMgr
@@ -36,15 +44,50 @@ type devFactory struct {
 }
 
 func (d *devFactory) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if d.remoteProxy.IsHealthy() {
+	// writes are queued behind pending ones until they are replayed, even if remote server is healthy
+	info, _ := apirequest.RequestInfoFrom(req.Context())
+	if d.remoteProxy.IsHealthy() && !d.writeQueue.mustQueue(info) {
+		util.SetServingSource(req.Context(), util.SourceRemote)
 		d.remoteProxy.ServeHTTP(rw, req)
 		return
 	}
 
 	// if remoteProxy not healthy, then use localProxy
+	util.SetServingSource(req.Context(), util.SourceLocal)
 	d.localProxy.ServeHTTP(rw, req)
 }
 
+// Drain wait for in-flight cache writes when edge proxy is shutting down
+func (d *devFactory) Drain(ctx context.Context) error {
+	if d.remote == nil {
+		return nil
+	}
+	return d.remote.Drain(ctx)
+}
+
+// ListCache list cached lists for debug endpoints
+func (d *devFactory) ListCache() (interface{}, error) {
+	return d.cacheMgr.ListCache()
+}
+
+// GetCache get a cached list by key for debug endpoints
+func (d *devFactory) GetCache(key string) ([]byte, error) {
+	return d.cacheMgr.GetCache(key)
+}
+
+// EvictCache remove a cached list by key for debug endpoints
+func (d *devFactory) EvictCache(key string) error {
+	return d.cacheMgr.EvictCache(key)
+}
+
+// RemoteStatus health status of remote server for debug endpoints
+func (d *devFactory) RemoteStatus() interface{} {
+	if d.remote == nil {
+		return []HealthStatus{}
+	}
+	return []HealthStatus{d.remote.HealthStatus()}
+}
+
 //initCacheMgr init cache mgr
 func (d *devFactory) initCacheMgr() (*CacheMgr, error) {
 	storageManager, err := util.NewDiskStorage(d.cfg.DiskCachePath)
@@ -60,11 +103,7 @@ func (d *devFactory) Init(cfg *config.EdgeProxyConfiguration, stopCh <-chan stru
 
 	d.cfg = cfg
 
-	serverCfg := &server.Config{
-		LegacyAPIGroupPrefixes: sets.NewString(server.DefaultLegacyAPIPrefix),
-	}
-	resolver := server.NewRequestInfoResolver(serverCfg)
-	d.resolver = resolver
+	d.classifier = util.NewRequestClassifier(proxy.NewRequestInfoResolver())
 
 	remoteServer := cfg.RemoteServers[0] // 假设一定成立
 
@@ -74,21 +113,61 @@ func (d *devFactory) Init(cfg *config.EdgeProxyConfiguration, stopCh <-chan stru
 	}
 
 	d.cacheMgr = cacheMgr
+
+	// init lease store for local lease renewal when remote server is unhealthy
+	var leaseStore *LeaseStore
+	if cfg.EnableLocalLease {
+		leaseStore = NewLeaseStore(cacheMgr.storage, remoteServer, cfg.RT)
+	}
+
 	// init remoteProxy
-	lb, _ := NewRemoteProxy(remoteServer, cacheMgr, cfg.RT, stopCh)
+	lb, _ := NewRemoteProxy(remoteServer, cacheMgr, leaseStore, cfg, stopCh)
+	if leaseStore != nil {
+		go leaseStore.run(lb.IsHealthy, stopCh)
+	}
 	d.remoteProxy = lb
+	d.remote = lb
+
+	// init write queue for mutating requests when remote server is unhealthy
+	var writeQueue *WriteQueue
+	if len(cfg.OfflineWriteResources) != 0 {
+		writeQueue, err = NewWriteQueue(cacheMgr.storage, cfg.OfflineWriteResources, remoteServer, cfg.RT, cfg.EnableImpersonation)
+		if err != nil {
+			return nil, err
+		}
+		go writeQueue.run(lb.IsHealthy, stopCh)
+	}
+	d.writeQueue = writeQueue
 
 	// init localProxy
-	d.localProxy = NewLocalProxy(cacheMgr, lb.IsHealthy)
+	localProxy := NewLocalProxy(cacheMgr, lb.IsHealthy, writeQueue, leaseStore)
+	d.localProxy = localProxy
+	if cfg.ServeStaleOnThrottle {
+		lb.SetStaleServer(localProxy)
+	}
 
-	return d.buildHandlerChain(d), nil
+	return d.buildHandlerChain(d)
 }
 
-// buildHandlerChain use middleware for handler
-func (d *devFactory) buildHandlerChain(handler http.Handler) http.Handler {
-	handler = d.returnCacheResourceUsage(handler)
+// buildHandlerChain use middlewares configured by cfg.Middlewares for handler,
+// requests are always classified before handler, because proxies depend on request info.
+func (d *devFactory) buildHandlerChain(handler http.Handler) (http.Handler, error) {
+	ctx := &proxy.MiddlewareContext{
+		Cfg:        d.cfg,
+		Classifier: d.classifier,
+		IsHealthy:  d.remoteProxy.IsHealthy,
+		Values:     map[string]interface{}{devFactoryKey: d},
+	}
+	return proxy.BuildHandlerChain(util.WithRequestClassification(handler, d.classifier), d.cfg.Middlewares, ctx)
+}
 
-	return handler
+// newResourceCacheMiddleware return resourceusage list from memory cache, it's only applicable for dev handler
+func newResourceCacheMiddleware(ctx *proxy.MiddlewareContext) (proxy.Middleware, error) {
+	d, ok := ctx.Values[devFactoryKey].(*devFactory)
+	if !ok {
+		return nil, nil
+	}
+	return d.returnCacheResourceUsage, nil
 }
 
 //returnCacheResourceUsage if labelSelector contains type=resourceusage, then return mem data if ok
@@ -96,7 +175,7 @@ func (d *devFactory) returnCacheResourceUsage(handler http.Handler) http.Handler
 	var count int
 
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodGet {
+		if req.Method != http.MethodGet || !d.cfg.Runtime.EnableMemoryCache() {
 			handler.ServeHTTP(rw, req)
 			return
 		}
@@ -106,17 +185,20 @@ func (d *devFactory) returnCacheResourceUsage(handler http.Handler) http.Handler
 			//klog.Infof("return resource cache")
 			count++
 			klog.V(5).Infof("resource usage count is %v", count)
-			res, ok := d.cacheMgr.QueryCacheMem("configmaps", d.resourceNs, resourceType)
+			comp, _ := util.ClientComponentFrom(req.Context())
+			res, ok := d.cacheMgr.QueryCacheMem(req.Context(), comp, "configmaps", d.resourceNs, resourceType)
 			if !ok {
 				klog.Errorf("may be not resource cache")
 				goto end
 			}
 
-			rw.Header().Set("Content-Type", "application/json")
-			rw.WriteHeader(http.StatusOK)
-			_, err := rw.Write(res)
+			util.SetServingSource(req.Context(), util.SourceCache)
+			err := serveCachedList(rw, req, res)
 			if err != nil {
-				klog.Errorf("rw.Write err: %v", err)
+				if _, ok := err.(apierrors.APIStatus); ok {
+					util.WriteErrStatus(rw, err)
+					return
+				}
 				goto end
 			}
 			// return if not err
@@ -124,16 +206,15 @@ func (d *devFactory) returnCacheResourceUsage(handler http.Handler) http.Handler
 		}
 	end:
 
-		info, err := d.resolver.NewRequestInfo(req)
+		req, err := d.classifier.Classify(req)
 		if err != nil {
-			klog.Errorf("resolver request info err: %v", err)
+			util.WriteErrStatus(rw, err)
 			return
 		}
-		// inject info
-		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
-		// no resource cache
+		info, _ := apirequest.RequestInfoFrom(req.Context())
+		// no resource cache, a page of list is not cached
 		handler.ServeHTTP(rw, req)
-		if checkLabel(info, labelSelector, resourceLabel) {
+		if checkLabel(info, labelSelector, resourceLabel) && !isPagedList(req.URL.Query()) {
 			d.resourceCache = true        // set cache true
 			d.resourceNs = info.Namespace // set ns
 			count++
@@ -0,0 +1,63 @@
+package dev
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// impersonatedUser user info of caller, it's persisted with queued requests
+type impersonatedUser struct {
+	Name   string              `json:"name"`
+	UID    string              `json:"uid,omitempty"`
+	Groups []string            `json:"groups,omitempty"`
+	Extra  map[string][]string `json:"extra,omitempty"`
+}
+
+// newImpersonatedUser convert user.Info to impersonatedUser, nil is returned for anonymous caller
+func newImpersonatedUser(u user.Info) *impersonatedUser {
+	if u == nil || u.GetName() == "" {
+		return nil
+	}
+	return &impersonatedUser{
+		Name:   u.GetName(),
+		UID:    u.GetUID(),
+		Groups: u.GetGroups(),
+		Extra:  u.GetExtra(),
+	}
+}
+
+// setImpersonationHeaders remove Impersonate-* headers supplied by caller,
+// so callers can not impersonate others with the credential of edge proxy,
+// then impersonate u if it's not nil.
+func setImpersonationHeaders(h http.Header, u *impersonatedUser) {
+	for k := range h {
+		if strings.HasPrefix(k, authenticationv1.ImpersonateUserExtraHeaderPrefix) {
+			h.Del(k)
+		}
+	}
+	h.Del(authenticationv1.ImpersonateUserHeader)
+	h.Del(authenticationv1.ImpersonateGroupHeader)
+	h.Del(authenticationv1.ImpersonateUIDHeader)
+
+	if u == nil {
+		return
+	}
+	h.Set(authenticationv1.ImpersonateUserHeader, u.Name)
+	for _, g := range u.Groups {
+		// system:authenticated is added by kube-apiserver
+		if g == user.AllAuthenticated {
+			continue
+		}
+		h.Add(authenticationv1.ImpersonateGroupHeader, g)
+	}
+	for k, values := range u.Extra {
+		key := authenticationv1.ImpersonateUserExtraHeaderPrefix + url.PathEscape(k)
+		for _, v := range values {
+			h.Add(key, v)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package dev
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestSetImpersonationHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Impersonate-User", "system:admin")
+	h.Add("Impersonate-Group", "system:masters")
+	h.Set("Impersonate-Extra-Scopes", "all")
+	h.Set("Accept", "application/json")
+
+	u := &user.DefaultInfo{
+		Name:   "system:node:node1",
+		Groups: []string{"system:nodes", user.AllAuthenticated},
+		Extra:  map[string][]string{"authentication.kubernetes.io/pod-name": {"p1"}},
+	}
+	setImpersonationHeaders(h, newImpersonatedUser(u))
+
+	if got := h.Get("Impersonate-User"); got != "system:node:node1" {
+		t.Errorf("Impersonate-User = %s, want system:node:node1", got)
+	}
+	if got := h.Values("Impersonate-Group"); !reflect.DeepEqual(got, []string{"system:nodes"}) {
+		t.Errorf("Impersonate-Group = %v, want [system:nodes]", got)
+	}
+	if got := h.Get("Impersonate-Extra-Scopes"); got != "" {
+		t.Errorf("Impersonate-Extra-Scopes of caller should be removed, got %s", got)
+	}
+	if got := h.Get("Impersonate-Extra-Authentication.kubernetes.io%2fpod-Name"); got != "p1" {
+		t.Errorf("extra should be impersonated, headers: %v", h)
+	}
+	if h.Get("Accept") != "application/json" {
+		t.Errorf("other headers should be kept")
+	}
+
+	// anonymous caller is forwarded without impersonation
+	setImpersonationHeaders(h, newImpersonatedUser(nil))
+	if h.Get("Impersonate-User") != "" || len(h.Values("Impersonate-Group")) != 0 {
+		t.Errorf("impersonation headers should be removed, headers: %v", h)
+	}
+}
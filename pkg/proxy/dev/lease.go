@@ -0,0 +1,357 @@
+package dev
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+
+	json "github.com/json-iterator/go"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/handlers/negotiation"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2"
+)
+
+// leasesPrefix storage key prefix for leases, it's reserved from component names by util.IsReservedComponent
+const leasesPrefix = "leases"
+
+// leaseGroupResource group resource of lease
+var leaseGroupResource = schema.GroupResource{Group: coordinationv1.GroupName, Resource: "leases"}
+
+// leaseCodecs serializers of leases, kubelet sends and accepts protobuf by default, others use json.
+// leases are kept in storage as json, and they are converted to the media type clients ask for.
+var leaseCodecs = scheme.Codecs.WithoutConversion()
+
+// isLeaseRequest check request is a get/update of coordination.k8s.io leases
+func isLeaseRequest(info *apirequest.RequestInfo) bool {
+	return info != nil && info.IsResourceRequest && info.APIGroup == coordinationv1.GroupName &&
+		info.Resource == "leases" && info.Subresource == "" && info.Name != "" &&
+		(info.Verb == "get" || info.Verb == "update")
+}
+
+// LeaseStore keeps leases locally, so lease renewals can be served when remote server is unhealthy,
+// and the latest renewal of each lease is pushed to remote server after reconnection.
+type LeaseStore struct {
+	sync.Mutex
+	// storage persist leases
+	storage storage.Store
+	// dirty keys of leases renewed locally and not pushed to remote server
+	dirty map[string]struct{}
+	// localVersion counter for resourceVersion of local renewals
+	localVersion int64
+	// remoteServer kube-apiserver url
+	remoteServer *url.URL
+	// client http client for pushing leases
+	client *http.Client
+}
+
+// NewLeaseStore create a lease store
+func NewLeaseStore(s storage.Store, remoteServer *url.URL, transport http.RoundTripper) *LeaseStore {
+	return &LeaseStore{
+		storage:      s,
+		dirty:        make(map[string]struct{}),
+		remoteServer: remoteServer,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// leaseKey generate a storage key for lease
+func leaseKey(ns, name string) string {
+	return filepath.Join(leasesPrefix, ns, name)
+}
+
+// CacheLease cache a lease returned by remote server, contentType is the media type of rc
+func (ls *LeaseStore) CacheLease(info *apirequest.RequestInfo, contentType string, rc io.ReadCloser) error {
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+
+	lease, err := decodeLease(contentType, body)
+	if err != nil {
+		klog.Errorf("%s decode err: %v", info.Resource, err)
+		return err
+	}
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+	key := leaseKey(info.Namespace, info.Name)
+	if _, ok := ls.dirty[key]; ok {
+		// local renewal has not been pushed, keep it
+		return nil
+	}
+	return ls.storage.Create(key, data)
+}
+
+// ServeHTTP serve lease get/update when remote server is unhealthy,
+// lease is decoded by Content-Type, and encoded in the media type negotiated by Accept.
+func (ls *LeaseStore) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	info, _ := apirequest.RequestInfoFrom(req.Context())
+	_, output, err := negotiation.NegotiateOutputMediaType(req, leaseCodecs, negotiation.DefaultEndpointRestrictions)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch info.Verb {
+	case "get":
+		data, err = ls.get(info.Namespace, info.Name)
+	case "update":
+		var body []byte
+		body, err = readRequestBody(req.Body)
+		if err != nil {
+			return err
+		}
+		var lease *coordinationv1.Lease
+		if lease, err = decodeLease(req.Header.Get("Content-Type"), body); err != nil {
+			return err
+		}
+		data, err = ls.renew(info.Namespace, info.Name, lease)
+	default:
+		return fmt.Errorf("err verb for lease: %s", info.Verb)
+	}
+	if err != nil {
+		return err
+	}
+	if data, err = encodeLease(output, data); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", output.MediaType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		klog.Errorf("rw.Write err: %v", err)
+	}
+	return err
+}
+
+// get return a lease from storage
+func (ls *LeaseStore) get(ns, name string) ([]byte, error) {
+	ls.Lock()
+	defer ls.Unlock()
+
+	data, err := ls.storage.Get(leaseKey(ns, name))
+	if err == storage.ErrStorageNotFound {
+		return nil, apierrors.NewNotFound(leaseGroupResource, name)
+	}
+	return data, err
+}
+
+// renew update a lease locally, optimistic concurrency is kept by resourceVersion,
+// the renewed lease is returned as json
+func (ls *LeaseStore) renew(ns, name string, lease *coordinationv1.Lease) ([]byte, error) {
+	if lease.Name != name || (lease.Namespace != "" && lease.Namespace != ns) {
+		return nil, apierrors.NewBadRequest("the name or namespace of the lease does not match the request")
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+
+	key := leaseKey(ns, name)
+	data, err := ls.storage.Get(key)
+	if err == storage.ErrStorageNotFound {
+		return nil, apierrors.NewNotFound(leaseGroupResource, name)
+	} else if err != nil {
+		return nil, err
+	}
+	var current coordinationv1.Lease
+	if err = json.Unmarshal(data, &current); err != nil {
+		return nil, err
+	}
+	if lease.ResourceVersion != "" && lease.ResourceVersion != current.ResourceVersion {
+		return nil, apierrors.NewConflict(leaseGroupResource, name,
+			fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+
+	// keep identity of the object, and generate a local resourceVersion
+	ls.localVersion++
+	lease.Namespace = ns
+	lease.UID = current.UID
+	lease.CreationTimestamp = current.CreationTimestamp
+	lease.ResourceVersion = fmt.Sprintf("%s-local-%d", strings.SplitN(current.ResourceVersion, "-", 2)[0], ls.localVersion)
+	newData, err := json.Marshal(lease)
+	if err != nil {
+		return nil, err
+	}
+	if err = ls.storage.Create(key, newData); err != nil {
+		return nil, err
+	}
+	ls.dirty[key] = struct{}{}
+	klog.V(4).Infof("lease %s/%s is renewed locally, holder: %v", ns, name, stringValue(lease.Spec.HolderIdentity))
+
+	return newData, nil
+}
+
+// run push the latest local renewals to remote server when it is healthy
+func (ls *LeaseStore) run(isHealthy IsHealthy, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			klog.Infof("lease store exit when received stopCh close")
+			return
+		case <-ticker.C:
+			if isHealthy() {
+				ls.sync()
+			}
+		}
+	}
+}
+
+// sync push dirty leases to remote server
+func (ls *LeaseStore) sync() {
+	ls.Lock()
+	keys := make([]string, 0, len(ls.dirty))
+	for key := range ls.dirty {
+		keys = append(keys, key)
+	}
+	ls.Unlock()
+
+	for _, key := range keys {
+		if err := ls.push(key); err != nil {
+			klog.Errorf("push lease %s err: %v", key, err)
+			continue
+		}
+		klog.Infof("lease %s is pushed to remote server", key)
+	}
+}
+
+// push push a local lease to remote server
+func (ls *LeaseStore) push(key string) error {
+	ls.Lock()
+	data, err := ls.storage.Get(key)
+	ls.Unlock()
+	if err != nil {
+		return err
+	}
+	var local coordinationv1.Lease
+	if err = json.Unmarshal(data, &local); err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("/apis/%s/%s/namespaces/%s/leases/%s", coordinationv1.GroupName,
+		coordinationv1.SchemeGroupVersion.Version, local.Namespace, local.Name)
+	code, remoteData, err := ls.do(http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("get lease from remote server, status code: %d", code)
+	}
+	var remote coordinationv1.Lease
+	if err = json.Unmarshal(remoteData, &remote); err != nil {
+		return err
+	}
+
+	// lease has been taken by others when disconnected, drop local renewal
+	if stringValue(remote.Spec.HolderIdentity) != stringValue(local.Spec.HolderIdentity) &&
+		remote.Spec.RenewTime != nil && local.Spec.RenewTime != nil && local.Spec.RenewTime.Before(remote.Spec.RenewTime) {
+		klog.Infof("lease %s is held by %s in remote server, drop local renewal", key, stringValue(remote.Spec.HolderIdentity))
+		return ls.finishPush(key, data, remoteData)
+	}
+
+	local.ResourceVersion = remote.ResourceVersion
+	local.UID = remote.UID
+	body, err := json.Marshal(local)
+	if err != nil {
+		return err
+	}
+	code, remoteData, err = ls.do(http.MethodPut, uri, body)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("update lease to remote server, status code: %d, %s", code, string(remoteData))
+	}
+
+	return ls.finishPush(key, data, remoteData)
+}
+
+// finishPush store lease from remote server, unless it has been renewed locally again
+func (ls *LeaseStore) finishPush(key string, pushed, remoteData []byte) error {
+	ls.Lock()
+	defer ls.Unlock()
+	current, err := ls.storage.Get(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, pushed) {
+		// renewed again when pushing, keep it dirty
+		return nil
+	}
+	delete(ls.dirty, key)
+	return ls.storage.Create(key, remoteData)
+}
+
+// do send a request to remote server
+func (ls *LeaseStore) do(method, uri string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, ls.remoteServer.String()+uri, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := ls.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// decodeLease decode a lease in media type of contentType, json is used if it's empty
+func decodeLease(contentType string, data []byte) (*coordinationv1.Lease, error) {
+	input, err := negotiation.NegotiateInputSerializerForMediaType(contentType, false, leaseCodecs)
+	if err != nil {
+		return nil, err
+	}
+	lease := &coordinationv1.Lease{}
+	if _, _, err = input.Serializer.Decode(data, nil, lease); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("decode lease err: %v", err))
+	}
+	// type meta is not set by protobuf serializer
+	lease.TypeMeta = metav1.TypeMeta{Kind: "Lease", APIVersion: coordinationv1.SchemeGroupVersion.String()}
+	return lease, nil
+}
+
+// encodeLease convert a json lease from storage to the media type of output
+func encodeLease(output runtime.SerializerInfo, data []byte) ([]byte, error) {
+	lease, err := decodeLease(runtime.ContentTypeJSON, data)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.Encode(leaseCodecs.EncoderForVersion(output.Serializer, coordinationv1.SchemeGroupVersion), lease)
+}
+
+// stringValue return value of a string pointer
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,216 @@
+package dev
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+// newLeaseObject create lease node-a of kube-node-lease
+func newLeaseObject(rv, holder string, renewTime time.Time) *coordinationv1.Lease {
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		TypeMeta:   metav1.TypeMeta{Kind: "Lease", APIVersion: "coordination.k8s.io/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Namespace: "kube-node-lease", ResourceVersion: rv, UID: "uid-a"},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, RenewTime: &renew},
+	}
+}
+
+func newLease(t *testing.T, rv, holder string, renewTime time.Time) []byte {
+	data, err := json.Marshal(newLeaseObject(rv, holder, renewTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLeaseStoreRenew(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Create(leaseKey("kube-node-lease", "node-a"), newLease(t, "100", "node-a", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLeaseStore(s, &url.URL{}, nil)
+
+	data, err := ls.renew("kube-node-lease", "node-a", newLeaseObject("100", "node-a", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lease coordinationv1.Lease
+	if err = json.Unmarshal(data, &lease); err != nil {
+		t.Fatal(err)
+	}
+	if lease.ResourceVersion != "100-local-1" || lease.UID != "uid-a" {
+		t.Errorf("expect local resourceVersion and uid are kept, got %q, %q", lease.ResourceVersion, lease.UID)
+	}
+	if got, err := ls.get("kube-node-lease", "node-a"); err != nil || string(got) != string(data) {
+		t.Errorf("expect renewed lease is served, got %s, %v", string(got), err)
+	}
+
+	// renewal based on the old resourceVersion conflicts
+	_, err = ls.renew("kube-node-lease", "node-a", newLeaseObject("100", "node-a", time.Now()))
+	if !apierrors.IsConflict(err) {
+		t.Errorf("expect conflict for stale resourceVersion, got %v", err)
+	}
+	if _, err = ls.renew("kube-node-lease", "node-a", newLeaseObject(lease.ResourceVersion, "node-a", time.Now())); err != nil {
+		t.Errorf("expect renewal with the local resourceVersion, got %v", err)
+	}
+	if _, err = ls.get("kube-node-lease", "node-b"); !apierrors.IsNotFound(err) {
+		t.Errorf("expect not found for lease which is not cached, got %v", err)
+	}
+}
+
+func TestLeaseStoreSync(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name         string
+		remoteHolder string
+		remoteRenew  time.Time
+		expectPut    bool
+		expectRV     string
+	}{
+		{"reconcile local renewal", "node-a", start, true, "121"},
+		{"taken by others", "node-b", start.Add(time.Hour), false, "120"},
+	}
+	for _, tt := range tests {
+		var put *coordinationv1.Lease
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.Method {
+			case http.MethodGet:
+				w.Write(newLease(t, "120", tt.remoteHolder, tt.remoteRenew))
+			case http.MethodPut:
+				body, _ := io.ReadAll(req.Body)
+				put = &coordinationv1.Lease{}
+				json.Unmarshal(body, put)
+				w.Write(newLease(t, "121", "node-a", start.Add(time.Minute)))
+			}
+		}))
+		u, _ := url.Parse(upstream.URL)
+
+		s, err := util.NewDiskStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := leaseKey("kube-node-lease", "node-a")
+		if err = s.Create(key, newLease(t, "100", "node-a", start)); err != nil {
+			t.Fatal(err)
+		}
+		ls := NewLeaseStore(s, u, http.DefaultTransport)
+		if _, err = ls.renew("kube-node-lease", "node-a", newLeaseObject("", "node-a", start.Add(time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+
+		ls.sync()
+		upstream.Close()
+		if (put != nil) != tt.expectPut {
+			t.Fatalf("%s: expect put %v, got %v", tt.name, tt.expectPut, put)
+		}
+		if put != nil && put.ResourceVersion != "120" {
+			t.Errorf("%s: expect local renewal is pushed with remote resourceVersion, got %q", tt.name, put.ResourceVersion)
+		}
+		if len(ls.dirty) != 0 {
+			t.Errorf("%s: expect no dirty lease after sync", tt.name)
+		}
+
+		// lease of remote server is kept after sync
+		data, err := s.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lease coordinationv1.Lease
+		if err = json.Unmarshal(data, &lease); err != nil {
+			t.Fatal(err)
+		}
+		if lease.ResourceVersion != tt.expectRV {
+			t.Errorf("%s: expect lease %s from remote server, got %q", tt.name, tt.expectRV, lease.ResourceVersion)
+		}
+	}
+}
+
+func TestLeaseStoreProtobuf(t *testing.T) {
+	const protobuf = "application/vnd.kubernetes.protobuf"
+	codec := func(mediaType string) runtime.Codec {
+		info, ok := runtime.SerializerInfoForMediaType(leaseCodecs.SupportedMediaTypes(), mediaType)
+		if !ok {
+			t.Fatalf("no serializer for %s", mediaType)
+		}
+		return scheme.Codecs.CodecForVersions(info.Serializer, info.Serializer, coordinationv1.SchemeGroupVersion, coordinationv1.SchemeGroupVersion)
+	}
+	serve := func(ls *LeaseStore, verb, accept string, body []byte) *httptest.ResponseRecorder {
+		method := http.MethodGet
+		if verb == "update" {
+			method = http.MethodPut
+		}
+		req := httptest.NewRequest(method, "/apis/coordination.k8s.io/v1/namespaces/kube-node-lease/leases/node-a", bytes.NewReader(body))
+		req.Header.Set("Accept", accept)
+		if body != nil {
+			req.Header.Set("Content-Type", protobuf)
+		}
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: verb, APIGroup: "coordination.k8s.io", APIVersion: "v1",
+			Namespace: "kube-node-lease", Resource: "leases", Name: "node-a"}
+		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+		w := httptest.NewRecorder()
+		if err := ls.ServeHTTP(w, req); err != nil {
+			t.Fatalf("serve %s err: %v", verb, err)
+		}
+		return w
+	}
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLeaseStore(s, &url.URL{}, nil)
+	// kubelet gets lease from remote server in protobuf
+	data, err := runtime.Encode(codec(protobuf), newLeaseObject("100", "node-a", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &apirequest.RequestInfo{Verb: "get", Resource: "leases", Namespace: "kube-node-lease", Name: "node-a"}
+	if err = ls.CacheLease(info, protobuf, io.NopCloser(bytes.NewReader(data))); err != nil {
+		t.Fatalf("cache protobuf lease err: %v", err)
+	}
+
+	w := serve(ls, "get", protobuf+", */*", nil)
+	if w.Header().Get("Content-Type") != protobuf {
+		t.Fatalf("expect protobuf lease, got %q", w.Header().Get("Content-Type"))
+	}
+	lease := &coordinationv1.Lease{}
+	if err = runtime.DecodeInto(codec(protobuf), w.Body.Bytes(), lease); err != nil || lease.ResourceVersion != "100" {
+		t.Fatalf("expect lease 100 in protobuf, got %v, %v", lease.ResourceVersion, err)
+	}
+
+	// renewal is sent in protobuf
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(time.Minute)}
+	body, err := runtime.Encode(codec(protobuf), lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(ls, "update", protobuf, body)
+	renewed := &coordinationv1.Lease{}
+	if err = runtime.DecodeInto(codec(protobuf), w.Body.Bytes(), renewed); err != nil || renewed.ResourceVersion != "100-local-1" {
+		t.Fatalf("expect renewed lease in protobuf, got %v, %v", renewed.ResourceVersion, err)
+	}
+
+	// json clients get the same lease in json
+	w = serve(ls, "get", "application/json", nil)
+	if err = json.Unmarshal(w.Body.Bytes(), lease); err != nil || lease.ResourceVersion != "100-local-1" || lease.Kind != "Lease" {
+		t.Errorf("expect renewed lease in json, got %s, %v", w.Body.String(), err)
+	}
+}
@@ -2,13 +2,26 @@ package dev
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
+
+	json "github.com/json-iterator/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog/v2"
 )
 
+// localWatchCheckInterval interval for checking remote server of local watches
+const localWatchCheckInterval = time.Second
+
 // IsHealthy is func for fetching healthy status of remote server
 type IsHealthy func() bool
 
@@ -17,13 +30,19 @@ type LocalProxy struct {
 	sync.RWMutex
 	cacheMgr  *CacheMgr
 	isHealthy IsHealthy
+	// writeQueue queue for mutating requests, nil means writes are rejected
+	writeQueue *WriteQueue
+	// leaseStore serve lease get/update locally, nil means disabled
+	leaseStore *LeaseStore
 }
 
 // NewLocalProxy creates a *LocalProxy
-func NewLocalProxy(cacheMgr *CacheMgr, isHealthy IsHealthy) *LocalProxy {
+func NewLocalProxy(cacheMgr *CacheMgr, isHealthy IsHealthy, writeQueue *WriteQueue, leaseStore *LeaseStore) *LocalProxy {
 	return &LocalProxy{
-		cacheMgr:  cacheMgr,
-		isHealthy: isHealthy,
+		cacheMgr:   cacheMgr,
+		isHealthy:  isHealthy,
+		writeQueue: writeQueue,
+		leaseStore: leaseStore,
 	}
 }
 
@@ -32,13 +51,25 @@ func (lp *LocalProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var err error
 	ctx := req.Context()
 	if reqInfo, ok := apirequest.RequestInfoFrom(ctx); ok && reqInfo != nil && reqInfo.IsResourceRequest {
-		switch reqInfo.Verb {
-		default: // list, get, update
+		switch {
+		case lp.leaseStore != nil && isLeaseRequest(reqInfo):
+			// lease get/update are served by lease store, so leader election still works
+			err = lp.leaseStore.ServeHTTP(w, req)
+		case reqInfo.Verb == "watch":
+			err = lp.localWatch(w, req)
+		case lp.writeQueue.accept(reqInfo):
+			// create, update, patch, delete for resources enabled by --offline-write-resources
+			err = lp.localWrite(w, req)
+		default: // list, get
 			err = lp.localReqCache(w, req)
 		}
 
 		if err != nil {
 			klog.Errorf("could not proxy local for %s %v", reqInfo.Resource, err)
+			if _, ok := err.(apierrors.APIStatus); ok {
+				util.WriteErrStatus(w, err)
+				return
+			}
 			w.WriteHeader(http.StatusBadRequest)
 		}
 	} else {
@@ -49,10 +80,9 @@ func (lp *LocalProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 // localReqCache handles Get/List/Update requests when remote servers are unhealthy
 func (lp *LocalProxy) localReqCache(w http.ResponseWriter, req *http.Request) error {
-	klog.Infof("now req cache...")
-
 	// filter consistency
 	info, _ := apirequest.RequestInfoFrom(req.Context())
+	logs.FromContext(req.Context(), logs.Local).V(4).InfoS("Serve request from cache", "request", util.ReqInfoString(info))
 
 	labelSelector := req.URL.Query().Get("labelSelector") // filter then enter
 	if !checkLabel(info, labelSelector, consistencyLabel) {
@@ -64,23 +94,251 @@ func (lp *LocalProxy) localReqCache(w http.ResponseWriter, req *http.Request) er
 		return fmt.Errorf("get cache mgr err")
 	}
 
-	obj, err := lp.cacheMgr.QueryCache(info, consistencyType)
+	comp, _ := util.ClientComponentFrom(req.Context())
+	obj, err := lp.cacheMgr.QueryCache(req.Context(), comp, info, consistencyType)
 	if err != nil {
 		klog.Errorf("查询缓存失败 err: %v", err)
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(obj)
+	// honor limit/continue/resourceVersionMatch like kube-apiserver
+	return serveCachedList(w, req, obj)
+}
+
+// localWatch serve watch from cached consistency list when remote servers are unhealthy.
+// watch from "" or "0" gets ADDED events of cached items, watch from a resourceVersion older than
+// cache gets 410, so client relists from cache. otherwise watch is held with a bookmark of cached
+// resourceVersion until remote servers are healthy, then client re-watches upstream without relist.
+// objects are sent as PartialObjectMetadata if Accept header asks for, like metadata informers.
+func (lp *LocalProxy) localWatch(w http.ResponseWriter, req *http.Request) error {
+	info, _ := apirequest.RequestInfoFrom(req.Context())
+	query := req.URL.Query()
+	if !isConsistencyWatch(info, query.Get("labelSelector")) {
+		return fmt.Errorf("not watch consistency label")
+	}
+	target, err := negotiateWatchTarget(req.Header.Get("Accept"))
 	if err != nil {
-		klog.Errorf("rw.Write err: %v", err)
 		return err
 	}
 
+	if lp.cacheMgr == nil {
+		klog.Errorf("cache mgr is nil")
+		return fmt.Errorf("get cache mgr err")
+	}
+
+	comp, _ := util.ClientComponentFrom(req.Context())
+	data, err := lp.cacheMgr.QueryCache(req.Context(), comp, info, consistencyType)
+	if err != nil {
+		return err
+	}
+	var list cachedList
+	if err = json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("decode cached list err: %w", err)
+	}
+
+	var events [][]byte
+	expired := false
+	switch rv := query.Get("resourceVersion"); rv {
+	case "", "0":
+		for _, item := range list.Items {
+			if target != nil {
+				if item, err = toPartialObjectMetadata(item, target.version); err != nil {
+					return err
+				}
+			}
+			ev, err := encodeWatchEvent("ADDED", item)
+			if err != nil {
+				return err
+			}
+			events = append(events, ev)
+		}
+	default:
+		requested, err := strconv.ParseUint(rv, 10, 64)
+		if err != nil {
+			return apierrors.NewBadRequest(fmt.Sprintf("invalid resource version: %q", rv))
+		}
+		current, _ := strconv.ParseUint(list.Metadata.ResourceVersion, 10, 64)
+		if requested < current {
+			// events between requested and cached resourceVersion are not kept
+			status := apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %d (%d)", requested, current)).ErrStatus
+			ev, err := encodeWatchEvent("ERROR", mustMarshalStatus(&status))
+			if err != nil {
+				return err
+			}
+			events, expired = append(events, ev), true
+		} else if requested > current {
+			// cache is behind client, a bookmark of cached resourceVersion moves client backward
+			list.Metadata.ResourceVersion = ""
+		}
+	}
+	if !expired && query.Get("allowWatchBookmarks") == "true" && list.Metadata.ResourceVersion != "" {
+		typeMeta := metav1.TypeMeta{Kind: strings.TrimSuffix(list.Kind, "List"), APIVersion: list.APIVersion}
+		if target != nil {
+			typeMeta = metav1.TypeMeta{Kind: asPartialObjectMetadata, APIVersion: metav1.GroupName + "/" + target.version}
+		}
+		bookmark, err := json.Marshal(&metav1.PartialObjectMetadata{
+			TypeMeta:   typeMeta,
+			ObjectMeta: metav1.ObjectMeta{ResourceVersion: list.Metadata.ResourceVersion},
+		})
+		if err != nil {
+			return err
+		}
+		ev, err := encodeWatchEvent("BOOKMARK", bookmark)
+		if err != nil {
+			return err
+		}
+		events = append(events, ev)
+	}
+
+	contentType := "application/json"
+	if target != nil {
+		contentType = target.contentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range events {
+		if _, err = w.Write(ev); err != nil {
+			klog.Errorf("rw.Write err: %v", err)
+			return nil
+		}
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	if expired {
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if seconds, err := strconv.ParseInt(query.Get("timeoutSeconds"), 10, 64); err == nil && seconds > 0 {
+		t := time.NewTimer(time.Duration(seconds) * time.Second)
+		defer t.Stop()
+		timeout = t.C
+	}
+	ticker := time.NewTicker(localWatchCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if lp.isHealthy() {
+				klog.V(4).Infof("remote server is healthy, close local watch of %s", info.Resource)
+				return nil
+			}
+		case <-timeout:
+			return nil
+		case <-req.Context().Done():
+			return nil
+		}
+	}
+}
+
+// serveStale serve list from cache when requests toward remote servers are throttled,
+// a Warning header is added, so clients know the response may be stale.
+func (lp *LocalProxy) serveStale(w http.ResponseWriter, req *http.Request) error {
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	if !ok || !info.IsResourceRequest || info.Verb != "list" {
+		return fmt.Errorf("only list can be served from cache")
+	}
+
+	w.Header().Set("Warning", `299 - "served from the cache of edge proxy because requests are throttled"`)
+	if err := lp.localReqCache(w, req); err != nil {
+		w.Header().Del("Warning")
+		return err
+	}
 	return nil
 }
 
+// localWrite queues a mutating request and applies it to cache optimistically,
+// the request will be replayed against remote server once it becomes healthy.
+// cache is only changed after the request is queued, so it never shows a write which will not be replayed.
+func (lp *LocalProxy) localWrite(w http.ResponseWriter, req *http.Request) error {
+	info, _ := apirequest.RequestInfoFrom(req.Context())
+
+	body, err := readRequestBody(req.Body)
+	if err != nil {
+		return err
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	queued := false
+	enqueue := func() error {
+		if _, err := lp.writeQueue.enqueue(req, info, body); err != nil {
+			klog.Errorf("queue %s err: %v", util.ReqInfoString(info), err)
+			return apierrors.NewServiceUnavailable(fmt.Sprintf("could not queue request, %v", err))
+		}
+		queued = true
+		return nil
+	}
+
+	var obj []byte
+	cached := false
+	if lp.cacheMgr != nil {
+		comp, _ := util.ClientComponentFrom(req.Context())
+		obj, err = lp.cacheMgr.UpdateCachedObject(comp, info, body, contentType, consistencyType, enqueue)
+		_, isStatus := err.(apierrors.APIStatus)
+		switch {
+		case err == nil:
+			cached = true
+		case queued:
+			// request is queued already, only cache is not changed
+			klog.Errorf("could not write %s to cache, %v", util.ReqInfoString(info), err)
+		case isStatus && !apierrors.IsNotFound(err):
+			return err
+		default:
+			// resource or object is not cached, e.g. object without the type label, only queue it
+			klog.V(4).Infof("skip to write %s to cache, %v", util.ReqInfoString(info), err)
+		}
+	}
+	if !queued {
+		if err = enqueue(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case !cached && (info.Verb == "create" || info.Verb == "update"):
+		// the result is unknown until it is replayed
+		obj = body
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+	case !cached || info.Verb == "delete":
+		code := http.StatusOK
+		if !cached {
+			code = http.StatusAccepted
+		}
+		status := metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+			Code:     int32(code),
+			Details:  &metav1.StatusDetails{Name: info.Name, Group: info.APIGroup, Kind: info.Resource},
+		}
+		data, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, err = w.Write(data)
+		return err
+	case info.Verb == "create":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_, err = w.Write(obj)
+	if err != nil {
+		klog.Errorf("rw.Write err: %v", err)
+	}
+	return err
+}
+
 // IsHealthy always return true
 func (lp *LocalProxy) IsHealthy() bool {
 	return true
@@ -0,0 +1,179 @@
+package dev
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+)
+
+func TestLocalWatch(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Create(KeyFunc(util.DefaultComponent, "pods", "default", consistencyType), newCachedPodList(t, "10", "a")); err != nil {
+		t.Fatal(err)
+	}
+	lp := NewLocalProxy(NewCacheMgr(s), func() bool { return false }, nil, nil)
+
+	watch := func(query string) []string {
+		return watchPods(t, lp, query, "")
+	}
+
+	events := watch("resourceVersion=0&allowWatchBookmarks=true&timeoutSeconds=1")
+	if len(events) != 2 || !strings.Contains(events[0], `"ADDED"`) ||
+		!strings.Contains(events[1], `"BOOKMARK"`) || !strings.Contains(events[1], `"resourceVersion":"10"`) {
+		t.Errorf("expect ADDED and BOOKMARK events, got %v", events)
+	}
+
+	// events after 5 are not kept in cache
+	events = watch("resourceVersion=5&allowWatchBookmarks=true")
+	if len(events) != 1 || !strings.Contains(events[0], `"ERROR"`) || !strings.Contains(events[0], `"code":410`) {
+		t.Errorf("expect 410 ERROR event, got %v", events)
+	}
+}
+
+// watchPods watch pods of default namespace from local proxy, events are returned after watch is closed
+func watchPods(t *testing.T, lp *LocalProxy, query, accept string) []string {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods?watch=true&"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+	w := httptest.NewRecorder()
+	lp.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200 for %s, got %d", query, w.Code)
+	}
+	if accept != "" && w.Header().Get("Content-Type") != "application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1" {
+		t.Errorf("expect negotiated content type, got %q", w.Header().Get("Content-Type"))
+	}
+	var events []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		events = append(events, scanner.Text())
+	}
+	return events
+}
+
+func TestLocalWatchPartialObjectMetadata(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Create(KeyFunc(util.DefaultComponent, "pods", "default", consistencyType), newCachedPodList(t, "10", "a")); err != nil {
+		t.Fatal(err)
+	}
+	lp := NewLocalProxy(NewCacheMgr(s), func() bool { return false }, nil, nil)
+
+	events := watchPods(t, lp, "resourceVersion=0&allowWatchBookmarks=true&timeoutSeconds=1",
+		"application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1,application/json")
+	if len(events) != 2 {
+		t.Fatalf("expect ADDED and BOOKMARK events, got %v", events)
+	}
+	for _, ev := range events {
+		if !strings.Contains(ev, `"kind":"PartialObjectMetadata","apiVersion":"meta.k8s.io/v1"`) {
+			t.Errorf("expect PartialObjectMetadata object, got %s", ev)
+		}
+	}
+
+	// Table watch can not be converted from cache
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods?watch=true", nil)
+	req.Header.Set("Accept", "application/json;as=Table;g=meta.k8s.io;v=v1")
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+	w := httptest.NewRecorder()
+	lp.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expect 406 for Table watch, got %d", w.Code)
+	}
+}
+
+// brokenStore is a storage which could not be written
+type brokenStore struct {
+	storage.Store
+	broken bool
+}
+
+func (s *brokenStore) Create(key string, data []byte) error {
+	if s.broken {
+		return errors.New("disk is broken")
+	}
+	return s.Store.Create(key, data)
+}
+
+func TestLocalWrite(t *testing.T) {
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := KeyFunc(util.DefaultComponent, "pods", "default", consistencyType)
+	if err = s.Create(key, newCachedPodList(t, "10", "a")); err != nil {
+		t.Fatal(err)
+	}
+	qs := &brokenStore{Store: s}
+	q, err := NewWriteQueue(qs, []string{"pods"}, &url.URL{Scheme: "https", Host: "127.0.0.1:6443"}, http.DefaultTransport, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := NewLocalProxy(NewCacheMgr(s), func() bool { return false }, q, nil)
+
+	write := func(verb, method, name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/namespaces/default/pods/"+name, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: verb, APIVersion: "v1", Namespace: "default", Resource: "pods", Name: name}
+		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+		w := httptest.NewRecorder()
+		lp.ServeHTTP(w, req)
+		return w
+	}
+	pod := func(name string) string {
+		return `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"` + name + `","namespace":"default","labels":{"type":"consistency"}}}`
+	}
+
+	// pod b is not in the cached list, it's only queued
+	if w := write("update", http.MethodPut, "b", pod("b")); w.Code != http.StatusAccepted {
+		t.Errorf("expect 202 for update of pod not cached, got %d %s", w.Code, w.Body.String())
+	}
+	if len(q.items) != 1 {
+		t.Fatalf("expect 1 queued request, got %d", len(q.items))
+	}
+
+	if w := write("update", http.MethodPut, "a", pod("a")); w.Code != http.StatusOK {
+		t.Errorf("expect 200 for update of cached pod, got %d %s", w.Code, w.Body.String())
+	}
+	if len(q.items) != 2 {
+		t.Fatalf("expect 2 queued requests, got %d", len(q.items))
+	}
+
+	// body is rejected instead of being truncated
+	if w := write("update", http.MethodPut, "a", strings.Repeat(" ", maxRequestBodyBytes)+pod("a")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect 413 for too large body, got %d", w.Code)
+	}
+
+	// cache is not changed when request could not be queued
+	qs.broken = true
+	if w := write("delete", http.MethodDelete, "a", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503 when request could not be queued, got %d", w.Code)
+	}
+	data, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"name":"a"`) {
+		t.Errorf("expect pod a is still cached, got %s", data)
+	}
+	if len(q.items) != 2 {
+		t.Errorf("expect 2 queued requests, got %d", len(q.items))
+	}
+}
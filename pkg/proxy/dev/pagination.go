@@ -0,0 +1,259 @@
+package dev
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	json "github.com/json-iterator/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// continueTokenVersion version of continue token issued by cached list
+const continueTokenVersion = "edge-proxy/v1"
+
+// cachedList generic view of a cached list response, items are kept raw
+type cachedList struct {
+	Kind       string            `json:"kind,omitempty"`
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Metadata   metav1.ListMeta   `json:"metadata"`
+	Items      []json.RawMessage `json:"items"`
+}
+
+// continueToken opaque token for the next page of a cached list
+// RV: resourceVersion of the cached list when the token is issued
+// Start: offset of the first item in next page
+type continueToken struct {
+	Version string `json:"v"`
+	RV      string `json:"rv"`
+	Start   int    `json:"start"`
+}
+
+// listPageOptions list options which affect the cached list response
+type listPageOptions struct {
+	limit                int64
+	continueKey          string
+	resourceVersion      string
+	resourceVersionMatch metav1.ResourceVersionMatch
+}
+
+// parseListPageOptions parse limit/continue/resourceVersion/resourceVersionMatch from query
+func parseListPageOptions(query url.Values) (*listPageOptions, error) {
+	opts := &listPageOptions{
+		continueKey:          query.Get("continue"),
+		resourceVersion:      query.Get("resourceVersion"),
+		resourceVersionMatch: metav1.ResourceVersionMatch(query.Get("resourceVersionMatch")),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || l < 0 {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid limit: %q", limit))
+		}
+		opts.limit = l
+	}
+
+	// same validation as kube-apiserver for list options
+	if opts.continueKey != "" && opts.resourceVersion != "" && opts.resourceVersion != "0" {
+		return nil, apierrors.NewBadRequest("specifying resource version is not allowed when using continue")
+	}
+	switch opts.resourceVersionMatch {
+	case "":
+	case metav1.ResourceVersionMatchExact, metav1.ResourceVersionMatchNotOlderThan:
+		if opts.resourceVersion == "" {
+			return nil, apierrors.NewBadRequest("resourceVersionMatch is forbidden unless resourceVersion is provided")
+		}
+		if opts.continueKey != "" {
+			return nil, apierrors.NewBadRequest("resourceVersionMatch is forbidden when continue is provided")
+		}
+		if opts.resourceVersionMatch == metav1.ResourceVersionMatchExact && opts.resourceVersion == "0" {
+			return nil, apierrors.NewBadRequest("resourceVersionMatch \"Exact\" is forbidden for resourceVersion \"0\"")
+		}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resourceVersionMatch: %q", opts.resourceVersionMatch))
+	}
+
+	return opts, nil
+}
+
+// isEmpty no options affect the cached list, so cached data can be returned directly
+func (o *listPageOptions) isEmpty() bool {
+	return o.limit == 0 && o.continueKey == "" && o.resourceVersionMatch == "" &&
+		(o.resourceVersion == "" || o.resourceVersion == "0")
+}
+
+// isPagedList check list request may get a page of items instead of all of them,
+// limit is ignored by kube-apiserver when list is served from watch cache with resourceVersion=0.
+func isPagedList(query url.Values) bool {
+	if query.Get("continue") != "" {
+		return true
+	}
+	if query.Get("limit") == "" || query.Get("limit") == "0" {
+		return false
+	}
+	return query.Get("resourceVersion") != "0" ||
+		metav1.ResourceVersionMatch(query.Get("resourceVersionMatch")) == metav1.ResourceVersionMatchExact
+}
+
+// paginateList apply list options to cached list data like kube-apiserver does
+// data: cached list response body
+func paginateList(data []byte, query url.Values) ([]byte, error) {
+	opts, err := parseListPageOptions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.isEmpty() {
+		return data, nil
+	}
+
+	var list cachedList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode cached list err: %w", err)
+	}
+
+	if err := checkResourceVersion(opts, list.Metadata.ResourceVersion); err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if opts.continueKey != "" {
+		token, err := decodeContinue(opts.continueKey)
+		if err != nil {
+			return nil, err
+		}
+		// cache has been refreshed since the token is issued, offset is not reliable any more
+		if token.RV != list.Metadata.ResourceVersion || token.Start > len(list.Items) {
+			return nil, apierrors.NewResourceExpired("the provided continue parameter is too old to display a consistent list result. You can start a new list without the continue parameter")
+		}
+		start = token.Start
+	}
+
+	// kube-apiserver serves list from watch cache and ignores limit when resourceVersion=0
+	limit := opts.limit
+	if opts.resourceVersion == "0" && opts.resourceVersionMatch != metav1.ResourceVersionMatchExact {
+		limit = 0
+	}
+
+	end := len(list.Items)
+	list.Metadata.Continue = ""
+	list.Metadata.RemainingItemCount = nil
+	if limit > 0 && int64(end-start) > limit {
+		end = start + int(limit)
+		next, err := encodeContinue(list.Metadata.ResourceVersion, end)
+		if err != nil {
+			return nil, err
+		}
+		remaining := int64(len(list.Items) - end)
+		list.Metadata.Continue = next
+		list.Metadata.RemainingItemCount = &remaining
+	}
+	list.Items = list.Items[start:end]
+	if list.Items == nil {
+		list.Items = []json.RawMessage{}
+	}
+
+	klog.V(5).Infof("paginate cached list, start: %d, end: %d, continue: %q", start, end, list.Metadata.Continue)
+	return json.Marshal(list)
+}
+
+// checkResourceVersion check cached list resourceVersion satisfies resourceVersion/resourceVersionMatch
+// listRV: resourceVersion of cached list, check will be skipped if it's unknown
+func checkResourceVersion(opts *listPageOptions, listRV string) error {
+	if listRV == "" || opts.resourceVersion == "" || opts.resourceVersion == "0" {
+		return nil
+	}
+
+	requested, err := strconv.ParseUint(opts.resourceVersion, 10, 64)
+	if err != nil {
+		return apierrors.NewBadRequest(fmt.Sprintf("invalid resource version: %q", opts.resourceVersion))
+	}
+	current, err := strconv.ParseUint(listRV, 10, 64)
+	if err != nil {
+		klog.Errorf("cached list has invalid resource version %q, skip check", listRV)
+		return nil
+	}
+
+	switch opts.resourceVersionMatch {
+	case metav1.ResourceVersionMatchExact:
+		if requested != current {
+			return apierrors.NewResourceExpired(fmt.Sprintf("resource version %d is not available in cache, current: %d", requested, current))
+		}
+	default: // NotOlderThan and legacy resourceVersion without match
+		if requested > current {
+			return apierrors.NewTimeoutError(fmt.Sprintf("Too large resource version: %d, current: %d", requested, current), 1)
+		}
+	}
+
+	return nil
+}
+
+// encodeContinue encode a continue token for cached list
+func encodeContinue(rv string, start int) (string, error) {
+	out, err := json.Marshal(&continueToken{Version: continueTokenVersion, RV: rv, Start: start})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// decodeContinue decode a continue token issued by encodeContinue
+func decodeContinue(continueValue string) (*continueToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(continueValue)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("continue key is not valid: %v", err))
+	}
+	var token continueToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("continue key is not valid: %v", err))
+	}
+	if token.Version != continueTokenVersion {
+		// maybe a token issued by kube-apiserver before remote server became unhealthy
+		return nil, apierrors.NewResourceExpired(fmt.Sprintf("continue key is not issued by cache: %q", token.Version))
+	}
+	if token.Start < 0 {
+		return nil, apierrors.NewBadRequest("continue key is not valid: negative start")
+	}
+	return &token, nil
+}
+
+// serveCachedList write cached list data to w after applying list options of req,
+// and convert it into Table or PartialObjectMetadataList if Accept header asks for.
+func serveCachedList(w http.ResponseWriter, req *http.Request, data []byte) error {
+	target, err := negotiateListTarget(req.Header.Get("Accept"))
+	if err != nil {
+		return err
+	}
+
+	query := req.URL.Query()
+	res, err := paginateList(data, query)
+	if err != nil {
+		return err
+	}
+
+	if target != nil {
+		res, err = convertList(res, target, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	contentType := "application/json"
+	if target != nil {
+		// client-go decodes response by the negotiated media type
+		contentType = target.contentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(res)
+	if err != nil {
+		klog.Errorf("rw.Write err: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package dev
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCachedPodList(t *testing.T, rv string, names ...string) []byte {
+	list := v1.PodList{
+		TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"},
+		ListMeta: metav1.ListMeta{ResourceVersion: rv},
+	}
+	for _, name := range names {
+		list.Items = append(list.Items, v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestPaginateListLimitContinue(t *testing.T) {
+	data := newCachedPodList(t, "100", "a", "b", "c", "d", "e")
+
+	var names []string
+	query := url.Values{"limit": []string{"2"}}
+	for i := 0; i < 5; i++ {
+		res, err := paginateList(data, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page v1.PodList
+		if err = json.Unmarshal(res, &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, pod := range page.Items {
+			names = append(names, pod.Name)
+		}
+		if page.Continue == "" {
+			if page.RemainingItemCount != nil {
+				t.Errorf("last page should not have remainingItemCount, got %d", *page.RemainingItemCount)
+			}
+			break
+		}
+		query.Set("continue", page.Continue)
+	}
+
+	if len(names) != 5 || names[0] != "a" || names[4] != "e" {
+		t.Errorf("expect all items in order, got %v", names)
+	}
+}
+
+func TestPaginateListExpiredContinue(t *testing.T) {
+	old := newCachedPodList(t, "100", "a", "b", "c")
+	res, err := paginateList(old, url.Values{"limit": []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page v1.PodList
+	if err = json.Unmarshal(res, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.RemainingItemCount == nil || *page.RemainingItemCount != 2 {
+		t.Errorf("expect remainingItemCount 2, got %v", page.RemainingItemCount)
+	}
+
+	// cache refreshed after the continue token was issued
+	refreshed := newCachedPodList(t, "120", "a", "b", "c")
+	_, err = paginateList(refreshed, url.Values{"limit": []string{"1"}, "continue": []string{page.Continue}})
+	if !apierrors.IsResourceExpired(err) {
+		t.Errorf("expect resource expired err, got %v", err)
+	}
+}
+
+func TestPaginateListResourceVersionMatch(t *testing.T) {
+	data := newCachedPodList(t, "100", "a", "b")
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int32
+	}{
+		{"exact match", url.Values{"resourceVersion": {"100"}, "resourceVersionMatch": {"Exact"}}, http.StatusOK},
+		{"exact mismatch", url.Values{"resourceVersion": {"90"}, "resourceVersionMatch": {"Exact"}}, http.StatusGone},
+		{"not older than", url.Values{"resourceVersion": {"90"}, "resourceVersionMatch": {"NotOlderThan"}}, http.StatusOK},
+		{"too large", url.Values{"resourceVersion": {"101"}, "resourceVersionMatch": {"NotOlderThan"}}, http.StatusGatewayTimeout},
+		{"match without rv", url.Values{"resourceVersionMatch": {"Exact"}}, http.StatusBadRequest},
+		{"unknown match", url.Values{"resourceVersion": {"100"}, "resourceVersionMatch": {"Newest"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := paginateList(data, tt.query)
+			code := int32(http.StatusOK)
+			if err != nil {
+				code = err.(apierrors.APIStatus).Status().Code
+			}
+			if code != tt.status {
+				t.Errorf("expect status %d, got %d, err: %v", tt.status, code, err)
+			}
+		})
+	}
+}
+
+func TestPaginateListIgnoreLimitForRV0(t *testing.T) {
+	data := newCachedPodList(t, "100", "a", "b", "c")
+	res, err := paginateList(data, url.Values{"limit": []string{"1"}, "resourceVersion": []string{"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page v1.PodList
+	if err = json.Unmarshal(res, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 3 || page.Continue != "" {
+		t.Errorf("expect full list for resourceVersion=0, got %d items", len(page.Items))
+	}
+}
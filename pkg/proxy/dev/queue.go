@@ -0,0 +1,318 @@
+package dev
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+
+	"github.com/google/uuid"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+)
+
+// define storage keys and result for write queue
+const (
+	queueRequestsKey = "queue/requests"
+	queueReportKey   = "queue/report"
+
+	replayResultReplayed = "replayed"
+	replayResultConflict = "conflict"
+	replayResultFailed   = "failed"
+
+	// queueReplayInterval interval for replaying queued requests, writes are queued until replay is finished
+	queueReplayInterval = time.Second
+)
+
+// queuedRequest a mutating request accepted locally when remote server is unhealthy
+type queuedRequest struct {
+	ID          string    `json:"id"`
+	Verb        string    `json:"verb"`
+	Method      string    `json:"method"`
+	URI         string    `json:"uri"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	Resource    string    `json:"resource"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// User caller of request, it's impersonated when replaying if impersonation is enabled
+	User *impersonatedUser `json:"user,omitempty"`
+}
+
+// replayResult result of a queued request after replaying against remote server
+type replayResult struct {
+	ID         string    `json:"id"`
+	Verb       string    `json:"verb"`
+	URI        string    `json:"uri"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Result     string    `json:"result"`
+	Message    string    `json:"message,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// replayReport report for the last replay
+type replayReport struct {
+	StartTime time.Time      `json:"startTime"`
+	EndTime   time.Time      `json:"endTime"`
+	Pending   int            `json:"pending"`
+	Results   []replayResult `json:"results"`
+}
+
+// WriteQueue is a write-ahead queue for mutating requests when remote server is unhealthy,
+// queued requests are persisted in storage and replayed in order once remote server is healthy.
+type WriteQueue struct {
+	sync.Mutex
+	// storage persist queued requests and replay report
+	storage storage.Store
+	// resources resources(or resource/subresource) which writes are accepted locally
+	resources sets.String
+	// items queued requests in order
+	items []*queuedRequest
+	// remoteServer kube-apiserver url
+	remoteServer *url.URL
+	// client http client for replaying
+	client *http.Client
+	// replayLock only one replay at the same time
+	replayLock sync.Mutex
+	// impersonate replay requests as their callers
+	impersonate bool
+}
+
+// NewWriteQueue create a write queue and recover queued requests from storage
+// resources: like "pods/status,events,leases"
+func NewWriteQueue(s storage.Store, resources []string, remoteServer *url.URL, transport http.RoundTripper, impersonate bool) (*WriteQueue, error) {
+	q := &WriteQueue{
+		storage:      s,
+		resources:    sets.NewString(resources...),
+		remoteServer: remoteServer,
+		impersonate:  impersonate,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}
+
+	data, err := s.Get(queueRequestsKey)
+	if err != nil && err != storage.ErrStorageNotFound {
+		return nil, err
+	}
+	if len(data) != 0 {
+		if err = json.Unmarshal(data, &q.items); err != nil {
+			return nil, fmt.Errorf("decode queued requests err: %w", err)
+		}
+		klog.Infof("recover %d queued requests from storage", len(q.items))
+	}
+
+	return q, nil
+}
+
+// accept check write of request can be queued or not
+func (q *WriteQueue) accept(info *apirequest.RequestInfo) bool {
+	if q == nil || info == nil || !info.IsResourceRequest {
+		return false
+	}
+	switch info.Verb {
+	case "create", "update", "patch", "delete":
+	default:
+		return false
+	}
+
+	if info.Subresource != "" {
+		return q.resources.Has(info.Resource + "/" + info.Subresource)
+	}
+	return q.resources.Has(info.Resource)
+}
+
+// enqueue persist a mutating request into queue
+func (q *WriteQueue) enqueue(req *http.Request, info *apirequest.RequestInfo, body []byte) (*queuedRequest, error) {
+	item := &queuedRequest{
+		ID:          uuid.New().String(),
+		Verb:        info.Verb,
+		Method:      req.Method,
+		URI:         req.URL.RequestURI(),
+		ContentType: req.Header.Get("Content-Type"),
+		Body:        body,
+		Resource:    info.Resource,
+		Namespace:   info.Namespace,
+		Name:        info.Name,
+		CreatedAt:   time.Now(),
+	}
+	if q.impersonate {
+		u, _ := apirequest.UserFrom(req.Context())
+		item.User = newImpersonatedUser(u)
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	q.items = append(q.items, item)
+	if err := q.persist(); err != nil {
+		q.items = q.items[:len(q.items)-1]
+		return nil, err
+	}
+	klog.Infof("queue %s %s, %d requests pending", item.Verb, item.URI, len(q.items))
+
+	return item, nil
+}
+
+// mustQueue check write of request should be queued even if remote server is healthy, because requests
+// are pending, so writes reach remote server in the order they are accepted until replay is finished.
+func (q *WriteQueue) mustQueue(info *apirequest.RequestInfo) bool {
+	return q.accept(info) && q.len() != 0
+}
+
+// len return count of pending requests
+func (q *WriteQueue) len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.items)
+}
+
+// persist should be called with q.Lock held
+func (q *WriteQueue) persist() error {
+	data, err := json.Marshal(q.items)
+	if err != nil {
+		return err
+	}
+	return q.storage.Create(queueRequestsKey, data)
+}
+
+// run replay queued requests when remote server is healthy
+func (q *WriteQueue) run(isHealthy IsHealthy, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(queueReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			klog.Infof("write queue exit when received stopCh close")
+			return
+		case <-ticker.C:
+			if q.len() != 0 && isHealthy() {
+				q.replay()
+			}
+		}
+	}
+}
+
+// replay send queued requests in order to remote server,
+// replay stops at the first request which fails because of remote server, it will be retried later.
+func (q *WriteQueue) replay() {
+	q.replayLock.Lock()
+	defer q.replayLock.Unlock()
+
+	report := &replayReport{StartTime: time.Now()}
+	for {
+		q.Lock()
+		if len(q.items) == 0 {
+			q.Unlock()
+			break
+		}
+		item := q.items[0]
+		q.Unlock()
+
+		result, retry := q.replayOne(item)
+		if retry {
+			klog.Errorf("replay %s %s failed, retry later: %s", item.Verb, item.URI, result.Message)
+			break
+		}
+		report.Results = append(report.Results, result)
+
+		q.Lock()
+		q.items = q.items[1:]
+		if err := q.persist(); err != nil {
+			klog.Errorf("persist queued requests err: %v", err)
+		}
+		q.Unlock()
+	}
+
+	report.EndTime = time.Now()
+	report.Pending = q.len()
+	if len(report.Results) == 0 {
+		return
+	}
+
+	counts := map[string]int{}
+	for i := range report.Results {
+		counts[report.Results[i].Result]++
+	}
+	klog.Infof("replay queued requests: %d replayed, %d conflict, %d failed, %d pending",
+		counts[replayResultReplayed], counts[replayResultConflict], counts[replayResultFailed], report.Pending)
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		klog.Errorf("marshal replay report err: %v", err)
+		return
+	}
+	if err = q.storage.Create(queueReportKey, data); err != nil {
+		klog.Errorf("storage create replay report err: %v", err)
+	}
+}
+
+// replayOne replay a queued request, retry is true if it should be replayed later
+func (q *WriteQueue) replayOne(item *queuedRequest) (replayResult, bool) {
+	result := replayResult{
+		ID:   item.ID,
+		Verb: item.Verb,
+		URI:  item.URI,
+		Time: time.Now(),
+	}
+
+	code, msg, err := q.send(item, item.Body)
+	if err != nil {
+		result.Message = err.Error()
+		return result, true
+	}
+
+	result.StatusCode = code
+	switch {
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		result.Result = replayResultReplayed
+		msg = ""
+	case code == http.StatusNotFound && item.Verb == "delete":
+		// object has been deleted already
+		result.Result = replayResultReplayed
+	case code == http.StatusConflict:
+		// object has been changed by others since it's written locally, overwriting it would lose their changes,
+		// so the write is dropped instead of being retried
+		result.Result = replayResultConflict
+	case code >= http.StatusInternalServerError || code == http.StatusTooManyRequests:
+		return result, true
+	default:
+		result.Result = replayResultFailed
+	}
+	result.Message = msg
+
+	return result, false
+}
+
+// send send a queued request with body to remote server, and return status code and message of response
+func (q *WriteQueue) send(item *queuedRequest, body []byte) (int, string, error) {
+	req, err := http.NewRequest(item.Method, q.remoteServer.String()+item.URI, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if item.ContentType != "" {
+		req.Header.Set("Content-Type", item.ContentType)
+	}
+	if q.impersonate {
+		setImpersonationHeaders(req.Header, item.User)
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+
+	return resp.StatusCode, strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,141 @@
+package dev
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+func TestWriteQueueReplay(t *testing.T) {
+	var lock sync.Mutex
+	var replayed []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		lock.Lock()
+		replayed = append(replayed, req.Method+" "+string(body))
+		lock.Unlock()
+		if req.Method == http.MethodGet {
+			t.Errorf("queued object should not be rebased by %s", req.URL)
+		}
+		if strings.Contains(string(body), "stale") {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"kind":"Status","code":409}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewWriteQueue(s, []string{"configmaps"}, u, http.DefaultTransport, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writes := []struct {
+		verb   string
+		method string
+		body   string
+	}{
+		{"create", http.MethodPost, "first"},
+		{"update", http.MethodPut, "stale"},
+		{"update", http.MethodPut, "last"},
+	}
+	for _, write := range writes {
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: write.verb, APIVersion: "v1", Namespace: "default", Resource: "configmaps", Name: "a"}
+		req := httptest.NewRequest(write.method, "/api/v1/namespaces/default/configmaps/a", strings.NewReader(write.body))
+		if !q.accept(info) {
+			t.Fatalf("expect %s of configmaps is accepted", write.verb)
+		}
+		if _, err = q.enqueue(req, info, []byte(write.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !q.mustQueue(&apirequest.RequestInfo{IsResourceRequest: true, Verb: "update", Resource: "configmaps"}) {
+		t.Errorf("expect writes are queued while requests are pending")
+	}
+
+	// queued requests are recovered from storage
+	q, err = NewWriteQueue(s, []string{"configmaps"}, u, http.DefaultTransport, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.len() != len(writes) {
+		t.Fatalf("expect %d requests recovered, got %d", len(writes), q.len())
+	}
+
+	q.replay()
+	expected := []string{"POST first", "PUT stale", "PUT last"}
+	if strings.Join(replayed, ",") != strings.Join(expected, ",") {
+		t.Errorf("expect requests are replayed in order %v, got %v", expected, replayed)
+	}
+	if q.len() != 0 || q.mustQueue(&apirequest.RequestInfo{IsResourceRequest: true, Verb: "update", Resource: "configmaps"}) {
+		t.Errorf("expect no pending requests after replay")
+	}
+
+	data, err := s.Get(queueReportKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report replayReport
+	if err = json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	results := []string{}
+	for _, r := range report.Results {
+		results = append(results, r.Result)
+	}
+	if strings.Join(results, ",") != "replayed,conflict,replayed" {
+		t.Errorf("expect conflict is reported for stale update, got %v", results)
+	}
+}
+
+func TestWriteQueueRetry(t *testing.T) {
+	healthy := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewWriteQueue(s, []string{"events"}, u, http.DefaultTransport, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "create", APIVersion: "v1", Namespace: "default", Resource: "events"}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/events", nil)
+	if _, err = q.enqueue(req, info, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	// request is kept when remote server fails
+	q.replay()
+	if q.len() != 1 {
+		t.Fatalf("expect request is kept for retry, got %d pending", q.len())
+	}
+	healthy = true
+	q.replay()
+	if q.len() != 0 {
+		t.Errorf("expect request is replayed, got %d pending", q.len())
+	}
+}
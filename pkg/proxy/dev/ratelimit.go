@@ -0,0 +1,148 @@
+package dev
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+// maxComponentLimiters max number of component limiters, components beyond it share one limiter
+const maxComponentLimiters = 256
+
+// remoteLimiter limit requests toward remote server by token buckets of component and verb,
+// so bursts of some agents do not use up the WAN link of edge node.
+type remoteLimiter struct {
+	sync.Mutex
+	// componentQPS and burst token bucket of each component, 0 qps means no limit for components
+	componentQPS rate.Limit
+	burst        int
+	components   map[string]*rate.Limiter
+	// overflow shared by components beyond maxComponentLimiters
+	overflow *rate.Limiter
+	// verbs token bucket of each verb, like list
+	verbs map[string]*rate.Limiter
+	// priority components which are not limited, lease requests are not limited either
+	priority sets.String
+	// queueTimeout max duration for waiting tokens
+	queueTimeout time.Duration
+}
+
+// newRemoteLimiter create a remoteLimiter, nil is returned if rate limit is disabled
+func newRemoteLimiter(cfg *config.EdgeProxyConfiguration) *remoteLimiter {
+	if cfg.RemoteComponentQPS <= 0 && len(cfg.RemoteVerbQPS) == 0 {
+		return nil
+	}
+
+	l := &remoteLimiter{
+		componentQPS: rate.Limit(cfg.RemoteComponentQPS),
+		burst:        cfg.RemoteBurst,
+		components:   map[string]*rate.Limiter{},
+		verbs:        map[string]*rate.Limiter{},
+		priority:     sets.NewString(cfg.PriorityComponents...),
+		queueTimeout: cfg.RemoteQueueTimeout,
+	}
+	if l.componentQPS > 0 {
+		l.overflow = rate.NewLimiter(l.componentQPS, l.burst)
+	}
+	for verb, qps := range cfg.RemoteVerbQPS {
+		if qps > 0 {
+			l.verbs[verb] = rate.NewLimiter(rate.Limit(qps), l.burst)
+		}
+	}
+	return l
+}
+
+// isPriority kubelet and lease requests are not limited, so node status and leader election are not delayed
+func (l *remoteLimiter) isPriority(comp string, info *apirequest.RequestInfo) bool {
+	if l.priority.Has(comp) {
+		return true
+	}
+	return info != nil && info.IsResourceRequest && info.APIGroup == coordinationv1.GroupName && info.Resource == "leases"
+}
+
+// limiters return token buckets of component and verb
+func (l *remoteLimiter) limiters(comp, verb string) []*rate.Limiter {
+	l.Lock()
+	defer l.Unlock()
+
+	var limiters []*rate.Limiter
+	if l.componentQPS > 0 {
+		lim, ok := l.components[comp]
+		if !ok {
+			if len(l.components) < maxComponentLimiters {
+				lim = rate.NewLimiter(l.componentQPS, l.burst)
+				l.components[comp] = lim
+			} else {
+				lim = l.overflow
+			}
+		}
+		limiters = append(limiters, lim)
+	}
+	if lim, ok := l.verbs[verb]; ok {
+		limiters = append(limiters, lim)
+	}
+	return limiters
+}
+
+// Wait wait until tokens of component and verb are available, requests are queued by reservations,
+// and an error is returned without consuming tokens if they can not be available before the deadline,
+// which is the earlier one of queueTimeout and deadline of ctx.
+func (l *remoteLimiter) Wait(ctx context.Context, comp string, info *apirequest.RequestInfo) error {
+	if l.isPriority(comp, info) {
+		return nil
+	}
+	verb := ""
+	if info != nil {
+		verb = info.Verb
+	}
+
+	now := time.Now()
+	deadline := now.Add(l.queueTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	var delay time.Duration
+	reservations := make([]*rate.Reservation, 0, 2)
+	cancelAll := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	for _, lim := range l.limiters(comp, verb) {
+		r := lim.ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if !r.OK() {
+			cancelAll()
+			return fmt.Errorf("rate limit of %s %s can not be satisfied", comp, verb)
+		}
+		if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	if now.Add(delay).After(deadline) {
+		cancelAll()
+		return fmt.Errorf("rate limit of %s %s exceeded, wait %v is over deadline", comp, verb, delay)
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		cancelAll()
+		return ctx.Err()
+	}
+}
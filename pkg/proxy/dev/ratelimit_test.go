@@ -0,0 +1,63 @@
+package dev
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+func TestRemoteLimiter(t *testing.T) {
+	if newRemoteLimiter(&config.EdgeProxyConfiguration{RemoteBurst: 1}) != nil {
+		t.Errorf("limiter should be disabled without qps")
+	}
+
+	l := newRemoteLimiter(&config.EdgeProxyConfiguration{
+		RemoteComponentQPS: 1,
+		RemoteVerbQPS:      map[string]int{"list": 1},
+		RemoteBurst:        1,
+		PriorityComponents: []string{"kubelet"},
+		RemoteQueueTimeout: 100 * time.Millisecond,
+	})
+	ctx := context.Background()
+	list := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"}
+	get := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods"}
+	lease := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "update", APIGroup: "coordination.k8s.io", Resource: "leases"}
+
+	if err := l.Wait(ctx, "flanneld", list); err != nil {
+		t.Fatalf("first request should not be throttled, %v", err)
+	}
+	// component bucket of flanneld and verb bucket of list are empty
+	if err := l.Wait(ctx, "flanneld", get); err == nil {
+		t.Errorf("component bucket should be exhausted")
+	}
+	if err := l.Wait(ctx, "coredns", list); err == nil {
+		t.Errorf("verb bucket should be exhausted")
+	}
+	// token of coredns is not consumed by the rejected list
+	if err := l.Wait(ctx, "coredns", get); err != nil {
+		t.Errorf("rejected request should not consume tokens, %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "kubelet", list); err != nil {
+			t.Errorf("priority component should not be throttled, %v", err)
+		}
+		if err := l.Wait(ctx, "flanneld", lease); err != nil {
+			t.Errorf("lease request should not be throttled, %v", err)
+		}
+	}
+
+	// requests are queued if tokens are available before the deadline
+	l.queueTimeout = 2 * time.Second
+	start := time.Now()
+	if err := l.Wait(ctx, "coredns", get); err != nil {
+		t.Errorf("request should wait for tokens, %v", err)
+	}
+	if time.Since(start) < 500*time.Millisecond {
+		t.Errorf("request should be queued until token is available")
+	}
+}
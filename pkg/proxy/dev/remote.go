@@ -1,14 +1,21 @@
 package dev
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync"
 
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/tracing"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 
+	"go.opentelemetry.io/otel/semconv"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog/v2"
 )
@@ -27,25 +34,72 @@ type RemoteProxy struct {
 	stopCh <-chan struct{}
 	// checker health checker
 	checker *checker
+	// leaseStore cache leases for local renewal, nil means disabled
+	leaseStore *LeaseStore
+	// impersonate forward requests as the authenticated caller by Impersonate-* headers
+	impersonate bool
+	// runtime filter prefix and memory cache settings, they can be changed at runtime
+	runtime *config.RuntimeConfiguration
+	// cacheWriters goroutines which write responses to cache
+	cacheWriters sync.WaitGroup
+	// limiter client side rate limit toward remote server, nil means disabled
+	limiter *remoteLimiter
+	// stale serve list from cache when requests are throttled, nil means 429 is returned
+	stale staleServer
+	// dedup collapse identical in-flight get/list requests, nil means disabled
+	dedup *dedupGroup
+	// watchMux share upstream watches among local watchers, nil means disabled
+	watchMux *watchMux
+	// events buffer events of shared watches which are applied to cache in background
+	events *eventBuffer
+	// compression ask remote server for gzip responses
+	compression bool
+	// watches watch responses from remote server, they are terminated when remote server becomes unhealthy
+	watches *watchTracker
+}
+
+// staleServer serve requests from local cache
+type staleServer interface {
+	serveStale(w http.ResponseWriter, req *http.Request) error
 }
 
 // NewRemoteProxy create a remote proxy
 func NewRemoteProxy(
 	remoteServer *url.URL,
 	cacheMgr *CacheMgr,
-	transport http.RoundTripper,
+	leaseStore *LeaseStore,
+	cfg *config.EdgeProxyConfiguration,
 	stopCh <-chan struct{},
 ) (*RemoteProxy, error) {
 
 	rproxy := &RemoteProxy{
 		remoteServer:     remoteServer,
-		currentTransport: transport,
+		currentTransport: cfg.RT,
 		cacheMgr:         cacheMgr,
+		leaseStore:       leaseStore,
+		impersonate:      cfg.EnableImpersonation,
+		runtime:          cfg.Runtime,
+		limiter:          newRemoteLimiter(cfg),
+		compression:      cfg.EnableCompression,
+		watches:          newWatchTracker(cfg.WatchIdleTimeout),
 		stopCh:           stopCh,
 	}
 
-	rproxy.checker = NewChecker(remoteServer)
+	if cfg.EnableRequestDedup {
+		rproxy.dedup = newDedupGroup()
+	}
+	if cfg.EnableWatchMultiplex {
+		rproxy.watchMux = newWatchMux(rproxy, remoteServer, cfg.EnableImpersonation, cfg.WatchHistorySize)
+		if cacheMgr != nil {
+			rproxy.events = newEventBuffer(cacheMgr, eventFlushInterval, &rproxy.cacheWriters)
+			rproxy.watchMux.observe = rproxy.cacheWatchEvent
+		}
+	}
+
+	rproxy.checker = NewChecker(remoteServer, cfg.Runtime.HealthCheck)
+	rproxy.checker.addListener(rproxy.onHealthChanged)
 	rproxy.checker.start(rproxy.stopCh) // start checker
+	go rproxy.watches.run(rproxy.stopCh)
 
 	// init reverse proxy for remoteServer
 	rproxy.reverseProxy = httputil.NewSingleHostReverseProxy(rproxy.remoteServer)
@@ -59,12 +113,121 @@ func NewRemoteProxy(
 }
 
 func (rp *RemoteProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if rp.limiter != nil {
+		comp, _ := util.ClientComponentFrom(req.Context())
+		info, _ := apirequest.RequestInfoFrom(req.Context())
+		if err := rp.limiter.Wait(req.Context(), comp, info); err != nil {
+			rp.throttled(rw, req, err)
+			return
+		}
+	}
+	if rp.watchMux != nil && canMultiplex(req) && rp.watchMux.serve(rw, req) {
+		return
+	}
 	rp.reverseProxy.ServeHTTP(rw, req)
 }
 
-func (rp *RemoteProxy) RoundTrip(request *http.Request) (*http.Response, error) {
-	// http.RoundTripper
-	return rp.currentTransport.RoundTrip(request)
+// throttled serve stale cache if it's possible, otherwise return 429 to client
+func (rp *RemoteProxy) throttled(rw http.ResponseWriter, req *http.Request, err error) {
+	klog.V(2).Infof("request %s is throttled, %v", req.URL.String(), err)
+	if rp.stale != nil {
+		staleErr := rp.stale.serveStale(rw, req)
+		if staleErr == nil {
+			util.SetServingSource(req.Context(), util.SourceCache)
+			return
+		}
+		klog.V(4).Infof("could not serve stale cache for %s, %v", req.URL.String(), staleErr)
+	}
+	util.WriteErrStatus(rw, apierrors.NewTooManyRequests("requests toward kube-apiserver are throttled by edge proxy, please try again later", 1))
+}
+
+// SetStaleServer serve list from cache of s when requests are throttled
+func (rp *RemoteProxy) SetStaleServer(s staleServer) {
+	rp.stale = s
+}
+
+func (rp *RemoteProxy) RoundTrip(request *http.Request) (resp *http.Response, err error) {
+	// RoundTripper should not modify the request, so it's cloned before headers are changed
+	cloned := false
+	clone := func() {
+		if !cloned {
+			request = request.Clone(request.Context())
+			cloned = true
+		}
+	}
+
+	if tracing.Enabled() {
+		ctx, span := tracing.Start(request.Context(), "remote RoundTrip", semconv.HTTPClientAttributesFromHTTPRequest(request)...)
+		defer func() {
+			if resp != nil {
+				span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+			}
+			tracing.End(span, err)
+		}()
+		// traceparent is sent to kube-apiserver, so its spans are in the same trace
+		request = request.Clone(ctx)
+		cloned = true
+		tracing.Inject(ctx, request.Header)
+	}
+	if rp.impersonate {
+		clone()
+		u, _ := apirequest.UserFrom(request.Context())
+		setImpersonationHeaders(request.Header, newImpersonatedUser(u))
+	}
+
+	// gzip responses are decompressed for clients which don't accept gzip,
+	// Accept-Encoding of clients is passed as it is when compression is disabled
+	decompress := false
+	if rp.compression && !acceptsGzip(request.Header) {
+		clone()
+		request.Header.Set("Accept-Encoding", "gzip")
+		decompress = true
+	}
+
+	if rp.dedup != nil && canDedup(request) {
+		resp, err = rp.dedup.RoundTrip(request, rp.currentTransport)
+	} else {
+		// http.RoundTripper
+		resp, err = rp.currentTransport.RoundTrip(request)
+	}
+	if err != nil {
+		return resp, err
+	}
+	if info, ok := apirequest.RequestInfoFrom(request.Context()); ok && info.Verb == "watch" && resp.StatusCode == http.StatusOK {
+		resp.Body = rp.watches.track(resp.Body, request.URL.Path)
+	}
+	if !decompress {
+		return resp, nil
+	}
+	return decompressResponse(resp), nil
+}
+
+// onHealthChanged terminate watches when remote server becomes unhealthy, watches may hang on
+// a dead connection, so clients re-watch against local proxy instead of waiting for them.
+func (rp *RemoteProxy) onHealthChanged(healthy bool) {
+	if healthy {
+		return
+	}
+	rp.watches.terminateAll("remote server is unhealthy")
+	if rp.watchMux != nil {
+		rp.watchMux.stopAll()
+	}
+}
+
+// cacheWatchEvent buffer an event of shared watch, it's applied to cached consistency list in background,
+// events of watches which are proxied directly are cached in modifyResponse.
+func (rp *RemoteProxy) cacheWatchEvent(ctx context.Context, query url.Values, eventType string, object []byte) {
+	info, ok := apirequest.RequestInfoFrom(ctx)
+	if !ok || !isConsistencyWatch(info, query.Get("labelSelector")) {
+		return
+	}
+	ev, err := newCachedEvent(eventType, object)
+	if err != nil {
+		klog.Errorf("could not cache event of %s, %v", info.Resource, err)
+		return
+	}
+	comp, _ := util.ClientComponentFrom(ctx)
+	rp.events.add(comp, info, ev, consistencyType)
 }
 
 func (rp *RemoteProxy) errorHandler(rw http.ResponseWriter, req *http.Request, err error) {
@@ -73,6 +236,22 @@ func (rp *RemoteProxy) errorHandler(rw http.ResponseWriter, req *http.Request, e
 	// todo: maybe can query from cacheMgr
 }
 
+// Drain wait for responses being written to cache, until ctx is done
+func (rp *RemoteProxy) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		rp.cacheWriters.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for cache writes, %w", ctx.Err())
+	}
+}
+
 func (rp *RemoteProxy) Name() string {
 	return rp.remoteServer.String()
 }
@@ -81,87 +260,134 @@ func (rp *RemoteProxy) IsHealthy() bool {
 	return rp.checker.isHealthy()
 }
 
+// HealthStatus health status of remote server and recent changes of it
+func (rp *RemoteProxy) HealthStatus() HealthStatus {
+	return rp.checker.status()
+}
+
 // modifyResponse modify response from kube-apiserver
 // it's important in this function
 // if request is not HTTP GET method, then return directly, because we only need to modify resp with HTTP GET method
 // for benchmark type is func, we should re-add Transfer-Encoding header to resp if verb is watch
-// for benchmark type is filter, we should filter resp if the item name include prefix "skip-"
+// for benchmark type is filter, we should filter resp if the item name include prefix "skip-"(--filter-prefix)
 // for benchmark type is consistency, we should cache list result and then filter item which label include type=consistency
 // for benchmark type is resourceusage, we can cache list result to memory, and on the next time if labelSelector with
 // resourceusage can query, then we can return it directly.
 func (rp *RemoteProxy) modifyResponse(resp *http.Response) error {
 	// no resp or no request
 	if resp == nil || resp.Request == nil {
-		klog.Infof("no request info in response, skip cache response")
+		logs.For(logs.Remote).V(4).InfoS("No request in response, skip caching response")
 		return nil
 	}
 
 	req := resp.Request
+	ctx := req.Context()
+	info, exists := apirequest.RequestInfoFrom(ctx)
+	logger := logs.FromContext(ctx, logs.Remote)
 
-	if req.Method != http.MethodGet {
+	// cache lease of get/update, so it can be renewed locally when remote server is unhealthy
+	if exists && rp.leaseStore != nil && isLeaseRequest(info) && resp.StatusCode == http.StatusOK {
+		rc, prc := util.NewDualReadCloser(req, resp.Body, true)
+		wrapPrc, _ := util.NewGZipReaderCloser(resp.Header, prc, info, "lease-store")
+		rp.cacheWriters.Add(1)
+		go func(contentType string, prc io.ReadCloser) {
+			defer rp.cacheWriters.Done()
+			if err := rp.leaseStore.CacheLease(info, contentType, prc); err != nil {
+				klog.Errorf("%s response cache ended with error, %v", info.Resource, err)
+			}
+		}(resp.Header.Get("Content-Type"), wrapPrc)
+		resp.Body = rc
 		return nil
 	}
 
-	ctx := req.Context()
+	if req.Method != http.MethodGet {
+		return nil
+	}
 
 	labelSelector := req.URL.Query().Get("labelSelector") // filter then enter
+	comp, _ := util.ClientComponentFrom(ctx)
 
 	// re-added transfer-encoding=chunked response header for watch request
-	info, exists := apirequest.RequestInfoFrom(ctx)
 	if exists {
 		if info.Verb == "watch" {
 			h := resp.Header
 			// http reverse proxy will del transfer-encoding header, so add this
 			if hv := h.Get("Transfer-Encoding"); hv == "" {
 				h.Add("Transfer-Encoding", "chunked")
-				klog.Infof("add Transfer-Encoding header")
+				logger.V(5).Sampled("transfer-encoding").InfoS("Add Transfer-Encoding header to watch response")
+			}
+
+			// advance cached list by events and bookmarks, so it can be served with a fresh resourceVersion
+			if resp.StatusCode == http.StatusOK && rp.cacheMgr != nil && isConsistencyWatch(info, labelSelector) {
+				rc, prc := util.NewDualReadCloser(req, resp.Body, true)
+				wrapPrc, _ := util.NewGZipReaderCloser(resp.Header, prc, info, "cache-manager")
+				// watch is long running, so it's not waited by Drain
+				go func(prc, wrapPrc io.ReadCloser) {
+					// prc should be drained, otherwise the watch response is blocked
+					defer io.Copy(io.Discard, prc)
+					if err := rp.cacheMgr.CacheWatchEvents(comp, info, wrapPrc, consistencyType); err != nil {
+						klog.Errorf("%s watch events cache ended with error, %v", info.Resource, err)
+					}
+				}(prc, wrapPrc)
+				resp.Body = rc
 			}
 		}
 	}
 
 	// success statusCode and is list request
-	if resp.StatusCode >= http.StatusOK && resp.StatusCode <= http.StatusPartialContent && info.Verb == "list" {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode <= http.StatusPartialContent && exists && info.Verb == "list" {
 		//klog.Infof("request info is %+v\n", info)
 		// filter response data
 		if checkLabel(info, labelSelector, filterLabel) {
 			// done: 重写 gzip reader 因为里面有对 component 进行获取
 			wrapBody, needUncompressed := util.NewGZipReaderCloser(resp.Header, resp.Body, info, "filter")
-			size, filterRc, err := NewFilterReadCloser(wrapBody, info.Resource, "skip-")
+			size, filterRc, err := NewFilterReadCloser(ctx, wrapBody, info.Resource, rp.runtime.FilterPrefix())
 			if err != nil {
 				klog.Errorf("failed to filter response for %s, %v", util.ReqInfoString(info), err)
 				return err
 			}
 			resp.Body = filterRc
+			// after gunzip in filter, re-compress it, because client accepts gzip
+			if needUncompressed {
+				size, resp.Body, err = gzipBody(filterRc)
+				if err != nil {
+					klog.Errorf("failed to compress response for %s, %v", util.ReqInfoString(info), err)
+					return err
+				}
+			}
 			if size > 0 {
 				resp.ContentLength = int64(size)
 				// re-set Content-Length
 				resp.Header.Set("Content-Length", fmt.Sprint(size))
 			}
 
-			// after gunzip in filter, the header content encoding should be removed.
-			// because there's no need to gunzip response.body again.
-			if needUncompressed {
-				resp.Header.Del("Content-Encoding")
-			}
-
-			klog.Infof("filter label not need to cache")
+			logger.V(4).InfoS("Filtered response is not cached", "request", util.ReqInfoString(info))
 			return nil
 		}
 
 		// funcational benchmark not need to cache
 		if checkLabel(info, labelSelector, funcLabel) {
-			klog.Infof("functional label not need to cache")
+			logger.V(4).InfoS("Functional response is not cached", "request", util.ReqInfoString(info))
+			return nil
+		}
+
+		// Table, PartialObjectMetadataList and protobuf responses are not cached, cache is converted when it's served
+		if !isPlainJSON(resp.Header.Get("Content-Type")) {
+			logger.V(4).InfoS("Response is not plain json, skip caching", "request", util.ReqInfoString(info),
+				"contentType", resp.Header.Get("Content-Type"))
 			return nil
 		}
 
 		// cache resourceusage when first invoke
-		if checkLabel(info, labelSelector, resourceLabel) {
+		if rp.runtime.EnableMemoryCache() && checkLabel(info, labelSelector, resourceLabel) && !isPagedList(req.URL.Query()) {
 			if rp.cacheMgr != nil && info.Namespace != "" {
 				rc, prc := util.NewDualReadCloser(req, resp.Body, true)
 				wrapPrc, _ := util.NewGZipReaderCloser(resp.Header, prc, info, "cache-manager")
+				rp.cacheWriters.Add(1)
 				go func(req *http.Request, prc io.ReadCloser) {
-					klog.Infof("cache resourceusage response")
-					err := rp.cacheMgr.CacheResponseMemNew(info, prc, resourceType)
+					defer rp.cacheWriters.Done()
+					logger.V(4).InfoS("Cache response", "request", util.ReqInfoString(info), "type", resourceType)
+					err := rp.cacheMgr.CacheResponseMemNew(comp, info, prc, resourceType)
 					if err != nil {
 						klog.Errorf("%s response cache ended with error, %v", info.Resource, err)
 					}
@@ -174,16 +400,19 @@ func (rp *RemoteProxy) modifyResponse(resp *http.Response) error {
 		}
 
 		// cache consistency list data
-		if (info.IsResourceRequest && info.Verb == "list" &&
+		// a page of list doesn't include all items, it should not replace the cached list
+		if ((info.IsResourceRequest && info.Verb == "list" &&
 			(info.Resource == "pods" || info.Resource == "configmaps") && labelSelector == "") ||
-			checkLabel(info, labelSelector, consistencyLabel) {
+			checkLabel(info, labelSelector, consistencyLabel)) && !isPagedList(req.URL.Query()) {
 			// cache resp with storage interface
 			if rp.cacheMgr != nil && info.Namespace != "" { // info.Namespace should not be empty
 				rc, prc := util.NewDualReadCloser(req, resp.Body, true)
 				wrapPrc, _ := util.NewGZipReaderCloser(resp.Header, prc, info, "cache-manager")
+				rp.cacheWriters.Add(1)
 				go func(req *http.Request, prc io.ReadCloser) {
-					klog.Infof("cache consistency response")
-					err := rp.cacheMgr.CacheResponse(info, prc, consistencyType)
+					defer rp.cacheWriters.Done()
+					logger.V(4).InfoS("Cache response", "request", util.ReqInfoString(info), "type", consistencyType)
+					err := rp.cacheMgr.CacheResponse(comp, info, prc, consistencyType)
 					if err != nil {
 						klog.Errorf("%s response cache ended with error, %v", info.Resource, err)
 					}
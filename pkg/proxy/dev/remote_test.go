@@ -0,0 +1,223 @@
+package dev
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	v1 "k8s.io/api/core/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/options"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+// newTestRemoteProxy create a remote proxy for upstream without health checker
+func newTestRemoteProxy(t *testing.T, upstream string, cacheMgr *CacheMgr) *RemoteProxy {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := &RemoteProxy{
+		remoteServer:     u,
+		currentTransport: http.DefaultTransport,
+		cacheMgr:         cacheMgr,
+		runtime:          config.NewRuntimeConfiguration(&options.EdgeProxyOptions{}),
+		watches:          newWatchTracker(0),
+	}
+	rp.reverseProxy = httputil.NewSingleHostReverseProxy(u)
+	rp.reverseProxy.Transport = rp
+	rp.reverseProxy.FlushInterval = -1
+	rp.reverseProxy.ModifyResponse = rp.modifyResponse
+	rp.reverseProxy.ErrorHandler = rp.errorHandler
+	return rp
+}
+
+// listPods list consistency pods of default namespace through handler
+func listPods(t *testing.T, handler http.Handler, query, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods?labelSelector=type%3Dconsistency&"+query, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+	req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRemoteProxyPagedListNotCached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		list := v1.PodList{}
+		list.ResourceVersion = "10"
+		for _, name := range []string{"a", "b", "c"} {
+			pod := v1.Pod{}
+			pod.Name, pod.Namespace, pod.Labels = name, "default", map[string]string{"type": consistencyType}
+			list.Items = append(list.Items, pod)
+		}
+		if req.URL.Query().Get("limit") == "1" {
+			list.Items = list.Items[:1]
+			list.Continue = "upstream-token"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer upstream.Close()
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheMgr := NewCacheMgr(s)
+	rp := newTestRemoteProxy(t, upstream.URL, cacheMgr)
+	for _, query := range []string{"", "limit=1"} {
+		if w := listPods(t, rp, query, ""); w.Code != http.StatusOK {
+			t.Fatalf("expect 200 for %q, got %d", query, w.Code)
+		}
+		if err = rp.Drain(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// first page of upstream should not replace the full list in cache
+	lp := NewLocalProxy(cacheMgr, func() bool { return false }, nil, nil)
+	w := listPods(t, lp, "", "")
+	var list v1.PodList
+	if err = json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 3 || list.Continue != "" {
+		t.Fatalf("expect all pods from cache, got %d items, continue %q", len(list.Items), list.Continue)
+	}
+
+	// continue token is issued by cache, so the next page can be served offline
+	w = listPods(t, lp, "limit=2", "")
+	if err = json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 || list.Continue == "" || strings.Contains(list.Continue, "upstream") {
+		t.Fatalf("expect first page with continue token of cache, got %d items, continue %q", len(list.Items), list.Continue)
+	}
+	w = listPods(t, lp, "limit=2&continue="+list.Continue, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200 for next page, got %d, %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRemoteProxyTableNotCached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "as=Table") {
+			w.Header().Set("Content-Type", "application/json;as=Table;g=meta.k8s.io;v=v1")
+			w.Write([]byte(`{"kind":"Table","apiVersion":"meta.k8s.io/v1","metadata":{"resourceVersion":"20"},"rows":[]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(newCachedPodList(t, "10", "a"))
+	}))
+	defer upstream.Close()
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := KeyFunc(util.DefaultComponent, "pods", "default", consistencyType)
+	if err = s.Create(key, newCachedPodList(t, "5", "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	cacheMgr := NewCacheMgr(s)
+	rp := newTestRemoteProxy(t, upstream.URL, cacheMgr)
+	tableAccept := "application/json;as=Table;g=meta.k8s.io;v=v1"
+	if w := listPods(t, rp, "", tableAccept); w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+	if err = rp.Drain(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listResourceVersion(data) != "5" {
+		t.Fatalf("expect Table response is not cached, got %s", string(data))
+	}
+
+	// Table is converted from cache with the negotiated media type
+	lp := NewLocalProxy(cacheMgr, func() bool { return false }, nil, nil)
+	w := listPods(t, lp, "", tableAccept)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tableAccept {
+		t.Errorf("expect Table from cache, got %d, %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRemoteProxyPagedResourceUsageNotCached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		list := v1.ConfigMapList{}
+		list.ResourceVersion = "10"
+		for _, name := range []string{"a", "b", "c"} {
+			cm := v1.ConfigMap{}
+			cm.Name, cm.Namespace, cm.Labels = name, "default", map[string]string{"type": resourceType}
+			list.Items = append(list.Items, cm)
+		}
+		if req.URL.Query().Get("limit") == "1" {
+			list.Items = list.Items[:1]
+			list.Continue = "upstream-token"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer upstream.Close()
+
+	s, err := util.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheMgr := NewCacheMgr(s)
+	rp := newTestRemoteProxy(t, upstream.URL, cacheMgr)
+	rp.runtime = config.NewRuntimeConfiguration(&options.EdgeProxyOptions{EnableMemoryCache: true})
+	d := &devFactory{
+		classifier: util.NewRequestClassifier(proxy.NewRequestInfoResolver()),
+		cfg:        &config.EdgeProxyConfiguration{Runtime: rp.runtime},
+		cacheMgr:   cacheMgr,
+	}
+	handler := d.returnCacheResourceUsage(rp)
+
+	list := func(query string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps?labelSelector=type%3Dresourceusage&"+query, nil)
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "configmaps"}
+		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expect 200 for %q, got %d", query, w.Code)
+		}
+		if err := rp.Drain(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a page of list is neither cached in memory nor served from memory later
+	list("limit=1")
+	if _, ok := cacheMgr.QueryCacheMem(context.TODO(), util.DefaultComponent, "configmaps", "default", resourceType); ok || d.resourceCache {
+		t.Fatalf("expect a page of resourceusage list is not cached")
+	}
+
+	list("")
+	data, ok := cacheMgr.QueryCacheMem(context.TODO(), util.DefaultComponent, "configmaps", "default", resourceType)
+	if !ok || !d.resourceCache {
+		t.Fatalf("expect full resourceusage list is cached")
+	}
+	var cached v1.ConfigMapList
+	if err = json.Unmarshal(data, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if len(cached.Items) != 3 || cached.Continue != "" {
+		t.Errorf("expect all configmaps in memory, got %d items, continue %q", len(cached.Items), cached.Continue)
+	}
+}
@@ -0,0 +1,469 @@
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxWatchRetries max number of continuous failures for re-watching upstream
+	maxWatchRetries = 3
+	// watchRetryInterval interval between re-watching upstream
+	watchRetryInterval = time.Second
+	// subscriberBuffer events buffered for each subscriber besides history, slow subscribers are closed
+	subscriberBuffer = 100
+)
+
+// errWatchExpired upstream watch is expired, subscribers should relist
+var errWatchExpired = errors.New("upstream watch is expired")
+
+// watchMux share one upstream watch among local watchers of the same resource, namespace,
+// selectors and identity, events are fanned out to all subscribers, and a short history of
+// events is kept, so subscribers can start from their requested resourceVersion.
+type watchMux struct {
+	sync.Mutex
+	// transport for upstream watches, it's RemoteProxy, so impersonation is applied, and upstream watches
+	// are tracked by its watch tracker like proxied ones. when an upstream watch is terminated by the tracker,
+	// the shared watch re-watches from lastRV, and subscribers are kept.
+	transport    http.RoundTripper
+	remoteServer *url.URL
+	// impersonate identity of upstream watches includes the user in request context
+	impersonate bool
+	historySize int
+	watches     map[string]*sharedWatch
+	// observe is called with events and bookmarks of shared watches, nil means disabled,
+	// ctx is the request context of the first subscriber, query has selectors of the shared watch.
+	// it's called on the read loop of upstream watch, so it should not block.
+	observe func(ctx context.Context, query url.Values, eventType string, object []byte)
+}
+
+func newWatchMux(transport http.RoundTripper, remoteServer *url.URL, impersonate bool, historySize int) *watchMux {
+	return &watchMux{
+		transport:    transport,
+		remoteServer: remoteServer,
+		impersonate:  impersonate,
+		historySize:  historySize,
+		watches:      map[string]*sharedWatch{},
+	}
+}
+
+// watchEvent an event of json watch stream
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+	// rv resourceVersion of object, data encoded event for subscribers
+	rv   uint64
+	data []byte
+}
+
+// canMultiplex only json watches of collections from a known resourceVersion are multiplexed,
+// watches from "" or "0" need the current state, which is not kept by watchMux.
+func canMultiplex(req *http.Request) bool {
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	if !ok || req.Method != http.MethodGet || !info.IsResourceRequest || info.Verb != "watch" || info.Name != "" {
+		return false
+	}
+	// legacy watch path like /api/v1/watch/pods
+	if strings.Contains(req.URL.Path, "/watch/") {
+		return false
+	}
+	query := req.URL.Query()
+	if rv, err := strconv.ParseUint(query.Get("resourceVersion"), 10, 64); err != nil || rv == 0 {
+		return false
+	}
+	if query.Get("resourceVersionMatch") != "" || query.Get("sendInitialEvents") != "" {
+		return false
+	}
+	accept := req.Header.Get("Accept")
+	return accept == "" || (strings.Contains(accept, "application/json") && !strings.Contains(accept, "protobuf")) || accept == "*/*"
+}
+
+// key watches of the same path, selectors and identity share the same upstream watch
+func (m *watchMux) key(req *http.Request) string {
+	h := req.Header.Clone()
+	if m.impersonate {
+		u, _ := apirequest.UserFrom(req.Context())
+		setImpersonationHeaders(h, newImpersonatedUser(u))
+	}
+	query := req.URL.Query()
+	return hashKey(h, req.URL.Path, query.Get("labelSelector"), query.Get("fieldSelector"))
+}
+
+// serve serve watch from the shared upstream watch, false is returned without writing anything
+// if events after requested resourceVersion are not in history, then it should be proxied directly.
+func (m *watchMux) serve(w http.ResponseWriter, req *http.Request) bool {
+	query := req.URL.Query()
+	rv, _ := strconv.ParseUint(query.Get("resourceVersion"), 10, 64)
+	key := m.key(req)
+
+	m.Lock()
+	sw, ok := m.watches[key]
+	if !ok {
+		sw = m.newSharedWatch(key, req, rv)
+		m.watches[key] = sw
+		go sw.run()
+	}
+	sub, err := sw.subscribe(rv, query.Get("allowWatchBookmarks") == "true")
+	m.Unlock()
+	if err != nil {
+		klog.V(4).Infof("could not join shared watch of %s, %v", req.URL.Path, err)
+		return false
+	}
+	defer m.unsubscribe(sw, sub)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var timeout <-chan time.Time
+	if seconds, err := strconv.ParseInt(query.Get("timeoutSeconds"), 10, 64); err == nil && seconds > 0 {
+		t := time.NewTimer(time.Duration(seconds) * time.Second)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for {
+		select {
+		case ev := <-sub.events:
+			if _, err := w.Write(ev.data); err != nil {
+				return true
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-sub.done:
+			if sub.closeErr != nil {
+				w.Write(sub.closeErr.data)
+			}
+			return true
+		case <-timeout:
+			return true
+		case <-req.Context().Done():
+			return true
+		}
+	}
+}
+
+// newSharedWatch create a shared watch, the upstream watch starts from rv,
+// request context of the first subscriber is kept for impersonation, but its cancellation is not.
+func (m *watchMux) newSharedWatch(key string, req *http.Request, rv uint64) *sharedWatch {
+	ctx, cancel := context.WithCancel(detachedContext{req.Context()})
+	query := url.Values{}
+	for _, name := range []string{"labelSelector", "fieldSelector"} {
+		if v := req.URL.Query().Get(name); v != "" {
+			query.Set(name, v)
+		}
+	}
+	return &sharedWatch{
+		mux:         m,
+		key:         key,
+		ctx:         ctx,
+		cancel:      cancel,
+		path:        req.URL.Path,
+		query:       query,
+		auth:        req.Header.Get("Authorization"),
+		coveredFrom: rv,
+		lastRV:      rv,
+		subscribers: map[*watchSubscriber]struct{}{},
+	}
+}
+
+// unsubscribe remove sub, and stop the shared watch if there is no subscriber
+func (m *watchMux) unsubscribe(sw *sharedWatch, sub *watchSubscriber) {
+	m.Lock()
+	defer m.Unlock()
+	sw.Lock()
+	defer sw.Unlock()
+
+	delete(sw.subscribers, sub)
+	if len(sw.subscribers) == 0 && !sw.stopped {
+		klog.V(4).Infof("stop shared watch of %s, no subscriber", sw.path)
+		sw.stopped = true
+		sw.cancel()
+		if m.watches[sw.key] == sw {
+			delete(m.watches, sw.key)
+		}
+	}
+}
+
+// stopAll stop all shared watches, subscribers are closed without error, so they re-watch from their resourceVersion
+func (m *watchMux) stopAll() {
+	m.Lock()
+	watches := make([]*sharedWatch, 0, len(m.watches))
+	for _, sw := range m.watches {
+		watches = append(watches, sw)
+	}
+	m.Unlock()
+
+	for _, sw := range watches {
+		sw.stop(nil)
+	}
+}
+
+// watchSubscriber a local watcher of shared watch
+type watchSubscriber struct {
+	events    chan *watchEvent
+	rv        uint64
+	bookmarks bool
+	// done is closed when shared watch is stopped or subscriber is too slow
+	done     chan struct{}
+	closeErr *watchEvent
+}
+
+// sharedWatch an upstream watch shared by subscribers
+type sharedWatch struct {
+	sync.Mutex
+	mux    *watchMux
+	key    string
+	ctx    context.Context
+	cancel context.CancelFunc
+	// path, query and auth of upstream watch
+	path  string
+	query url.Values
+	auth  string
+	// history recent events, all events after coveredFrom are in history
+	history     []*watchEvent
+	coveredFrom uint64
+	// lastRV resourceVersion of the last event or bookmark, upstream watch is restarted from it
+	lastRV      uint64
+	subscribers map[*watchSubscriber]struct{}
+	stopped     bool
+}
+
+// subscribe add a subscriber from rv, events in history after rv are replayed,
+// an error is returned if events after rv are not in history.
+func (sw *sharedWatch) subscribe(rv uint64, bookmarks bool) (*watchSubscriber, error) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	if rv < sw.coveredFrom {
+		return nil, fmt.Errorf("too old resource version: %d (%d)", rv, sw.coveredFrom)
+	}
+	sub := &watchSubscriber{
+		events:    make(chan *watchEvent, len(sw.history)+subscriberBuffer),
+		rv:        rv,
+		bookmarks: bookmarks,
+		done:      make(chan struct{}),
+	}
+	for _, ev := range sw.history {
+		if ev.rv > rv {
+			sub.events <- ev
+		}
+	}
+	sw.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+// run watch upstream until it's stopped, upstream watch is restarted from lastRV when it's closed
+func (sw *sharedWatch) run() {
+	failures := 0
+	for {
+		err := sw.watchOnce()
+		if sw.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errWatchExpired) {
+			return
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		failures++
+		klog.Errorf("shared watch of %s failed(%d), %v", sw.path, failures, err)
+		if failures > maxWatchRetries {
+			sw.stop(nil)
+			return
+		}
+		select {
+		case <-time.After(watchRetryInterval):
+		case <-sw.ctx.Done():
+			return
+		}
+	}
+}
+
+// watchOnce watch upstream from lastRV, and broadcast events until upstream watch is closed
+func (sw *sharedWatch) watchOnce() error {
+	sw.Lock()
+	query := url.Values{}
+	for k, v := range sw.query {
+		query[k] = v
+	}
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("resourceVersion", strconv.FormatUint(sw.lastRV, 10))
+	sw.Unlock()
+
+	u := *sw.mux.remoteServer
+	u.Path = sw.path
+	u.RawQuery = query.Encode()
+	// sw.ctx keeps the request info of the first subscriber, so RemoteProxy tracks the upstream watch
+	req, err := http.NewRequestWithContext(sw.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if sw.auth != "" {
+		req.Header.Set("Authorization", sw.auth)
+	}
+
+	resp, err := sw.mux.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		status := &metav1.Status{}
+		if err := json.Unmarshal(data, status); err == nil && status.Code != 0 {
+			return sw.expireIfGone(status)
+		}
+		return fmt.Errorf("upstream watch returns %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		ev := &watchEvent{}
+		if err := decoder.Decode(ev); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := sw.handle(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// handle parse ev and broadcast it to subscribers
+func (sw *sharedWatch) handle(ev *watchEvent) error {
+	if ev.Type == "ERROR" {
+		status := &metav1.Status{}
+		if err := json.Unmarshal(ev.Object, status); err != nil {
+			return err
+		}
+		return sw.expireIfGone(status)
+	}
+
+	meta := &struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(ev.Object, meta); err != nil {
+		return err
+	}
+	rv, err := strconv.ParseUint(meta.Metadata.ResourceVersion, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid resourceVersion %q of %s event", meta.Metadata.ResourceVersion, ev.Type)
+	}
+	ev.rv = rv
+	if ev.data, err = encodeWatchEvent(ev.Type, ev.Object); err != nil {
+		return err
+	}
+
+	if sw.mux.observe != nil {
+		sw.mux.observe(sw.ctx, sw.query, ev.Type, ev.Object)
+	}
+
+	sw.Lock()
+	defer sw.Unlock()
+	if rv > sw.lastRV {
+		sw.lastRV = rv
+	}
+	if ev.Type != "BOOKMARK" {
+		sw.history = append(sw.history, ev)
+		if len(sw.history) > sw.mux.historySize {
+			sw.coveredFrom = sw.history[0].rv
+			sw.history = sw.history[1:]
+		}
+	}
+
+	for sub := range sw.subscribers {
+		if ev.rv <= sub.rv || (ev.Type == "BOOKMARK" && !sub.bookmarks) {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			// subscriber is too slow, close it, so it re-watches from its last resourceVersion
+			klog.V(2).Infof("subscriber of shared watch %s is too slow, close it", sw.path)
+			delete(sw.subscribers, sub)
+			close(sub.done)
+		}
+	}
+	return nil
+}
+
+// expireIfGone stop shared watch with an ERROR event if upstream watch is expired
+func (sw *sharedWatch) expireIfGone(status *metav1.Status) error {
+	if status.Code != http.StatusGone {
+		return fmt.Errorf("upstream watch error: %s", status.Message)
+	}
+	data, err := encodeWatchEvent("ERROR", mustMarshalStatus(status))
+	if err != nil {
+		return err
+	}
+	sw.stop(&watchEvent{Type: "ERROR", data: data})
+	return errWatchExpired
+}
+
+// stop close all subscribers with closeErr, and remove shared watch from mux
+func (sw *sharedWatch) stop(closeErr *watchEvent) {
+	m := sw.mux
+	m.Lock()
+	defer m.Unlock()
+	sw.Lock()
+	defer sw.Unlock()
+
+	if m.watches[sw.key] == sw {
+		delete(m.watches, sw.key)
+	}
+	if sw.stopped {
+		return
+	}
+	sw.stopped = true
+	sw.cancel()
+	for sub := range sw.subscribers {
+		sub.closeErr = closeErr
+		close(sub.done)
+	}
+	sw.subscribers = map[*watchSubscriber]struct{}{}
+}
+
+func mustMarshalStatus(status *metav1.Status) []byte {
+	status.Kind, status.APIVersion = "Status", "v1"
+	data, _ := json.Marshal(status)
+	return data
+}
+
+// encodeWatchEvent encode an event of json watch stream
+func encodeWatchEvent(eventType string, object []byte) ([]byte, error) {
+	data, err := json.Marshal(&struct {
+		Type   string          `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}{Type: eventType, Object: object})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
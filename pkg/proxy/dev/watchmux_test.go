@@ -0,0 +1,239 @@
+package dev
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestWatchMux(t *testing.T) {
+	var upstreamWatches int32
+	events := make(chan string, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&upstreamWatches, 1)
+		if rv := req.URL.Query().Get("resourceVersion"); rv != "10" {
+			t.Errorf("upstream watch should start from 10, got %s", rv)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case ev := <-events:
+				fmt.Fprintln(w, ev)
+				w.(http.Flusher).Flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	remote, _ := url.Parse(upstream.URL)
+	m := newWatchMux(http.DefaultTransport, remote, false, 10)
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+		if !canMultiplex(req) || !m.serve(w, req) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	defer local.Close()
+
+	watch := func(rv string) (*http.Response, *bufio.Scanner) {
+		resp, err := http.Get(local.URL + "/api/v1/namespaces/default/pods?watch=true&resourceVersion=" + rv)
+		if err != nil {
+			t.Fatalf("watch err: %v", err)
+		}
+		return resp, bufio.NewScanner(resp.Body)
+	}
+	next := func(s *bufio.Scanner) string {
+		line := make(chan string, 1)
+		go func() {
+			s.Scan()
+			line <- s.Text()
+		}()
+		select {
+		case l := <-line:
+			return l
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no event is received")
+			return ""
+		}
+	}
+
+	respA, a := watch("10")
+	defer respA.Body.Close()
+	added := `{"type":"ADDED","object":{"kind":"Pod","metadata":{"name":"foo","resourceVersion":"11"}}}`
+	events <- added
+	if got := next(a); got != added {
+		t.Errorf("subscriber a got %s", got)
+	}
+
+	// b starts from 10, event 11 is replayed from history
+	respB, b := watch("10")
+	defer respB.Body.Close()
+	if got := next(b); got != added {
+		t.Errorf("subscriber b got %s", got)
+	}
+
+	modified := `{"type":"MODIFIED","object":{"kind":"Pod","metadata":{"name":"foo","resourceVersion":"12"}}}`
+	events <- modified
+	if got := next(a); got != modified {
+		t.Errorf("subscriber a got %s", got)
+	}
+	if got := next(b); got != modified {
+		t.Errorf("subscriber b got %s", got)
+	}
+	if n := atomic.LoadInt32(&upstreamWatches); n != 1 {
+		t.Errorf("expect 1 upstream watch, got %d", n)
+	}
+
+	// events before 10 are not in history, so it should be proxied directly
+	resp, err := http.Get(local.URL + "/api/v1/namespaces/default/pods?watch=true&resourceVersion=5")
+	if err != nil {
+		t.Fatalf("watch err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("watch from an old resourceVersion should not be multiplexed")
+	}
+
+	if canMultiplex(httptest.NewRequest(http.MethodGet, "/api/v1/pods?watch=true&resourceVersion=0", nil)) {
+		t.Errorf("watch without request info should not be multiplexed")
+	}
+
+	// shared watch is stopped when all subscribers are gone
+	respA.Body.Close()
+	respB.Body.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		m.Lock()
+		n := len(m.watches)
+		m.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Fatalf("shared watch is not stopped")
+		}
+	}
+}
+
+func TestWatchMuxSubscribersFromDifferentResourceVersions(t *testing.T) {
+	events := make(chan string, 10)
+	upstreamRVs := make(chan string, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		upstreamRVs <- req.URL.Query().Get("resourceVersion")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case ev := <-events:
+				fmt.Fprintln(w, ev)
+				w.(http.Flusher).Flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	// upstream watches are sent by remote proxy, so they are tracked like proxied watches
+	rp := newTestRemoteProxy(t, upstream.URL, nil)
+	m := newWatchMux(rp, rp.remoteServer, false, 10)
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info := &apirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", APIVersion: "v1", Namespace: "default", Resource: "pods"}
+		req = req.WithContext(apirequest.WithRequestInfo(req.Context(), info))
+		if !canMultiplex(req) || !m.serve(w, req) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	defer local.Close()
+
+	watch := func(rv string) (*http.Response, *bufio.Scanner) {
+		resp, err := http.Get(local.URL + "/api/v1/namespaces/default/pods?watch=true&resourceVersion=" + rv)
+		if err != nil {
+			t.Fatalf("watch err: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expect shared watch from %s, got %d", rv, resp.StatusCode)
+		}
+		return resp, bufio.NewScanner(resp.Body)
+	}
+	next := func(s *bufio.Scanner) string {
+		line := make(chan string, 1)
+		go func() {
+			s.Scan()
+			line <- s.Text()
+		}()
+		select {
+		case l := <-line:
+			return l
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no event is received")
+			return ""
+		}
+	}
+	event := func(eventType, rv string) string {
+		return fmt.Sprintf(`{"type":"%s","object":{"kind":"Pod","metadata":{"name":"foo","resourceVersion":"%s"}}}`, eventType, rv)
+	}
+
+	respA, a := watch("10")
+	defer respA.Body.Close()
+	if rv := <-upstreamRVs; rv != "10" {
+		t.Fatalf("upstream watch should start from 10, got %s", rv)
+	}
+	events <- event("ADDED", "11")
+	events <- event("MODIFIED", "12")
+	for _, ev := range []string{event("ADDED", "11"), event("MODIFIED", "12")} {
+		if got := next(a); got != ev {
+			t.Errorf("subscriber a got %s", got)
+		}
+	}
+
+	// b starts from 11, only event 12 is replayed; c starts from 12, nothing is replayed
+	respB, b := watch("11")
+	defer respB.Body.Close()
+	respC, c := watch("12")
+	defer respC.Body.Close()
+	if got := next(b); got != event("MODIFIED", "12") {
+		t.Errorf("subscriber b got %s", got)
+	}
+	events <- event("DELETED", "13")
+	for name, s := range map[string]*bufio.Scanner{"a": a, "b": b, "c": c} {
+		if got := next(s); got != event("DELETED", "13") {
+			t.Errorf("subscriber %s got %s", name, got)
+		}
+	}
+
+	// terminating the tracked upstream watch restarts it from the last event, subscribers are kept
+	rp.watches.Lock()
+	tracked := len(rp.watches.watches)
+	rp.watches.Unlock()
+	if tracked != 1 {
+		t.Fatalf("expect upstream watch is tracked, got %d", tracked)
+	}
+	rp.watches.terminateAll("test")
+	select {
+	case rv := <-upstreamRVs:
+		if rv != "13" {
+			t.Errorf("upstream watch should restart from 13, got %s", rv)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("upstream watch is not restarted")
+	}
+	events <- event("ADDED", "14")
+	for name, s := range map[string]*bufio.Scanner{"a": a, "b": b, "c": c} {
+		if got := next(s); got != event("ADDED", "14") {
+			t.Errorf("subscriber %s got %s", name, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package dev
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// minIdleCheckInterval min interval for checking idle watches
+const minIdleCheckInterval = time.Second
+
+// watchTracker track watch responses from remote server, so idle watches can be terminated,
+// and all watches can be terminated when remote server becomes unhealthy.
+// watches are ended like they are closed by remote server, so clients re-watch from their resourceVersion.
+type watchTracker struct {
+	sync.Mutex
+	// idleTimeout watches which receive nothing for idleTimeout are terminated, 0 means disabled
+	idleTimeout time.Duration
+	watches     map[*trackedWatch]struct{}
+}
+
+func newWatchTracker(idleTimeout time.Duration) *watchTracker {
+	return &watchTracker{
+		idleTimeout: idleTimeout,
+		watches:     map[*trackedWatch]struct{}{},
+	}
+}
+
+// trackedWatch body of a watch response
+type trackedWatch struct {
+	tracker *watchTracker
+	rc      io.ReadCloser
+	path    string
+	// lastRead unix nano of the last read data, terminated is set to 1 when it's terminated by proxy
+	lastRead   int64
+	terminated int32
+}
+
+// track wrap body of a watch response, it's untracked when it's closed
+func (t *watchTracker) track(rc io.ReadCloser, path string) io.ReadCloser {
+	w := &trackedWatch{tracker: t, rc: rc, path: path, lastRead: time.Now().UnixNano()}
+	t.Lock()
+	t.watches[w] = struct{}{}
+	t.Unlock()
+	return w
+}
+
+// terminateAll terminate all tracked watches
+func (t *watchTracker) terminateAll(reason string) {
+	t.Lock()
+	watches := make([]*trackedWatch, 0, len(t.watches))
+	for w := range t.watches {
+		watches = append(watches, w)
+	}
+	t.Unlock()
+
+	if len(watches) != 0 {
+		klog.Infof("terminate %d watches, %s", len(watches), reason)
+	}
+	for _, w := range watches {
+		w.terminate()
+	}
+}
+
+// run terminate idle watches until stopCh is closed
+func (t *watchTracker) run(stopCh <-chan struct{}) {
+	if t.idleTimeout <= 0 {
+		return
+	}
+	interval := t.idleTimeout / 2
+	if interval < minIdleCheckInterval {
+		interval = minIdleCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.terminateIdle(time.Now())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// terminateIdle terminate watches which receive nothing for idleTimeout before now
+func (t *watchTracker) terminateIdle(now time.Time) {
+	t.Lock()
+	idle := make([]*trackedWatch, 0)
+	for w := range t.watches {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&w.lastRead))) > t.idleTimeout {
+			idle = append(idle, w)
+		}
+	}
+	t.Unlock()
+
+	for _, w := range idle {
+		klog.V(2).Infof("watch of %s is idle for %v, terminate it", w.path, t.idleTimeout)
+		w.terminate()
+	}
+}
+
+// Read read from watch response, error is replaced by io.EOF after it's terminated,
+// so the response to client is ended normally instead of being aborted.
+func (w *trackedWatch) Read(p []byte) (int, error) {
+	n, err := w.rc.Read(p)
+	if n > 0 {
+		atomic.StoreInt64(&w.lastRead, time.Now().UnixNano())
+	}
+	if err != nil && atomic.LoadInt32(&w.terminated) == 1 {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// Close untrack and close the watch response
+func (w *trackedWatch) Close() error {
+	w.tracker.Lock()
+	delete(w.tracker.watches, w)
+	w.tracker.Unlock()
+	return w.rc.Close()
+}
+
+// terminate close the watch response, so the blocked Read returns
+func (w *trackedWatch) terminate() {
+	if atomic.CompareAndSwapInt32(&w.terminated, 0, 1) {
+		w.rc.Close()
+	}
+}
@@ -0,0 +1,56 @@
+package dev
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWatchTracker(t *testing.T) {
+	tracker := newWatchTracker(time.Minute)
+	read := func(rc io.Reader) chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := io.ReadAll(rc)
+			errCh <- err
+		}()
+		return errCh
+	}
+	wait := func(errCh chan error) {
+		select {
+		case err := <-errCh:
+			// watch is ended normally, so client re-watches
+			if err != nil {
+				t.Errorf("terminated watch should be ended by EOF, got %v", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("watch is not terminated")
+		}
+	}
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	idle := tracker.track(pr, "/api/v1/pods")
+	errCh := read(idle)
+	tracker.terminateIdle(time.Now())
+	select {
+	case <-errCh:
+		t.Fatalf("watch should not be terminated before idle timeout")
+	case <-time.After(100 * time.Millisecond):
+	}
+	tracker.terminateIdle(time.Now().Add(2 * time.Minute))
+	wait(errCh)
+	idle.Close()
+
+	pr, pw = io.Pipe()
+	defer pw.Close()
+	w := tracker.track(pr, "/api/v1/configmaps")
+	errCh = read(w)
+	tracker.terminateAll("remote server is unhealthy")
+	wait(errCh)
+	w.Close()
+
+	if len(tracker.watches) != 0 {
+		t.Errorf("closed watches should be untracked, got %d", len(tracker.watches))
+	}
+}
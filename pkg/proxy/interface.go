@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
 
 	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
@@ -9,3 +10,22 @@ import (
 type HandlerFactory interface {
 	Init(cfg *config.EdgeProxyConfiguration, stopCh <-chan struct{}) (http.Handler, error)
 }
+
+// Drainer is an optional interface of HandlerFactory,
+// it's called when edge proxy is shutting down, so in-flight work like cache writes can be finished.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// Inspector is an optional interface of HandlerFactory,
+// it exposes state of the handler like cache contents for debug endpoints of stub server.
+type Inspector interface {
+	// ListCache metadata of cached contents, like key, size, age and resourceVersion
+	ListCache() (interface{}, error)
+	// GetCache contents cached with key
+	GetCache(key string) ([]byte, error)
+	// EvictCache remove contents cached with key
+	EvictCache(key string) error
+	// RemoteStatus health status and its history of remote servers
+	RemoteStatus() interface{}
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "edge_proxy",
+		Name:      "requests_total",
+		Help:      "Number of requests handled by proxy server, partitioned by component, verb, resource and status code.",
+	}, []string{"component", "verb", "resource", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "edge_proxy",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of requests handled by proxy server except for long running ones, partitioned by component, verb and resource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"component", "verb", "resource"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration)
+}
+
+// newMetricsMiddleware record count and latency of requests, they are exposed by /metrics of stub server
+func newMetricsMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			rw := newStatusRecorder(w)
+			handler.ServeHTTP(rw, req)
+
+			comp, _ := util.ClientComponentFrom(req.Context())
+			verb, resource, longRunning := req.Method, "", false
+			if info, ok := apirequest.RequestInfoFrom(req.Context()); ok {
+				verb, resource = info.Verb, info.Resource
+			}
+			if class, ok := util.RequestClassFrom(req.Context()); ok {
+				longRunning = class.IsLongRunning
+			}
+			requestsTotal.WithLabelValues(comp, verb, resource, strconv.Itoa(rw.status)).Inc()
+			if !longRunning {
+				requestDuration.WithLabelValues(comp, verb, resource).Observe(time.Since(start).Seconds())
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/tracing"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+)
+
+// Middleware wrap a handler, and return the wrapped one
+type Middleware func(handler http.Handler) http.Handler
+
+// MiddlewareFactory build a middleware against the shared context,
+// nil Middleware means it's not applicable for the context, and it's skipped.
+type MiddlewareFactory func(ctx *MiddlewareContext) (Middleware, error)
+
+// MiddlewareContext is shared by all middlewares of a handler chain, it's prepared by handler factory
+type MiddlewareContext struct {
+	Cfg *config.EdgeProxyConfiguration
+	// Resolver resolve request info of requests, the default resolver is used if it's nil
+	Resolver apirequest.RequestInfoResolver
+	// Classifier classify requests with Resolver, it's created in BuildHandlerChain if it's nil
+	Classifier *util.RequestClassifier
+	// IsHealthy report remote servers are healthy or not, remote servers are treated as healthy if it's nil
+	IsHealthy func() bool
+	// Values objects of handler factory which are used by its own middlewares, like cache manager
+	Values map[string]interface{}
+
+	clientOnce sync.Once
+	client     kubernetes.Interface
+	clientErr  error
+}
+
+// KubeClient return a client for remote servers, it's created at the first call and shared by middlewares
+func (c *MiddlewareContext) KubeClient() (kubernetes.Interface, error) {
+	c.clientOnce.Do(func() {
+		if c.Cfg == nil || c.Cfg.RestConfig == nil {
+			c.clientErr = fmt.Errorf("rest config is not set")
+			return
+		}
+		c.client, c.clientErr = kubernetes.NewForConfig(c.Cfg.RestConfig)
+	})
+	return c.client, c.clientErr
+}
+
+// complete set defaults for fields which are not set
+func (c *MiddlewareContext) complete() {
+	if c.Resolver == nil {
+		c.Resolver = NewRequestInfoResolver()
+	}
+	if c.Classifier == nil {
+		c.Classifier = util.NewRequestClassifier(c.Resolver)
+	}
+	if c.IsHealthy == nil {
+		c.IsHealthy = func() bool { return true }
+	}
+	if c.Values == nil {
+		c.Values = map[string]interface{}{}
+	}
+}
+
+// NewRequestInfoResolver create a resolver for kube-apiserver requests
+func NewRequestInfoResolver() apirequest.RequestInfoResolver {
+	return server.NewRequestInfoResolver(&server.Config{
+		LegacyAPIGroupPrefixes: sets.NewString(server.DefaultLegacyAPIPrefix),
+	})
+}
+
+var middlewareFactories = map[string]MiddlewareFactory{}
+
+// RegisterMiddleware register a middleware factory with a unique name, it panics if the name is registered already
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	if _, ok := middlewareFactories[name]; ok {
+		panic(fmt.Sprintf("middleware %s already has been registered", name))
+	}
+	middlewareFactories[name] = factory
+}
+
+// ListMiddlewares return sorted names of registered middlewares
+func ListMiddlewares() []string {
+	names := make([]string, 0, len(middlewareFactories))
+	for name := range middlewareFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildHandlerChain wrap handler with middlewares in order of names,
+// the first one is the outermost, so it handles requests first.
+// when tracing is enabled, each middleware and handler is traced as a stage of the server span.
+func BuildHandlerChain(handler http.Handler, names []string, ctx *MiddlewareContext) (http.Handler, error) {
+	ctx.complete()
+
+	middlewares := make([]Middleware, 0, len(names))
+	enabled := make([]string, 0, len(names))
+	for _, name := range names {
+		factory, ok := middlewareFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("middleware %q is not registered, registered middlewares: %s",
+				name, strings.Join(ListMiddlewares(), ","))
+		}
+		m, err := factory(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not build middleware %s, %w", name, err)
+		}
+		if m == nil {
+			klog.V(2).Infof("middleware %s is skipped", name)
+			continue
+		}
+		middlewares = append(middlewares, m)
+		enabled = append(enabled, name)
+	}
+
+	handler = tracing.WithStageSpan(handler, "handler")
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = tracing.WithStageSpan(middlewares[i](handler), "middleware "+enabled[i])
+	}
+	handler = tracing.WithServerSpan(handler)
+	klog.Infof("handler chain is built with middlewares: %s", strings.Join(enabled, ","))
+	return handler, nil
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+func TestBuildHandlerChain(t *testing.T) {
+	var order []string
+	tagged := func(tag string) MiddlewareFactory {
+		return func(ctx *MiddlewareContext) (Middleware, error) {
+			return func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					order = append(order, tag)
+					handler.ServeHTTP(w, req)
+				})
+			}, nil
+		}
+	}
+	RegisterMiddleware("fake-a", tagged("a"))
+	RegisterMiddleware("fake-b", tagged("b"))
+	RegisterMiddleware("fake-skip", func(ctx *MiddlewareContext) (Middleware, error) { return nil, nil })
+	defer func() {
+		delete(middlewareFactories, "fake-a")
+		delete(middlewareFactories, "fake-b")
+		delete(middlewareFactories, "fake-skip")
+	}()
+
+	final := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "handler")
+	})
+	cfg := &config.EdgeProxyConfiguration{}
+	handler, err := BuildHandlerChain(final, []string{"fake-b", "fake-skip", "fake-a"}, &MiddlewareContext{Cfg: cfg})
+	if err != nil {
+		t.Fatalf("BuildHandlerChain err: %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/pods", nil))
+	if want := []string{"b", "a", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("middlewares are called in %v, want %v", order, want)
+	}
+
+	_, err = BuildHandlerChain(final, []string{"unknown"}, &MiddlewareContext{Cfg: cfg})
+	if err == nil || !strings.Contains(err.Error(), "fake-a") {
+		t.Errorf("expect error with registered middlewares, got %v", err)
+	}
+}
+
+func TestMaxInflightMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	final := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	})
+	cfg := &config.EdgeProxyConfiguration{MaxRequestsInflight: 1}
+	handler, err := BuildHandlerChain(final, []string{RequestInfoMiddleware, MaxInflightMiddleware}, &MiddlewareContext{Cfg: cfg})
+	if err != nil {
+		t.Fatalf("BuildHandlerChain err: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/pods", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/nodes", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expect 429 when max inflight is reached, got %d", w.Code)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/auth"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
+)
+
+// names of built-in middlewares, maxinflight is the rate limiting shared by handlers.
+// response filtering, rate limiting toward kube-apiserver and list caching are not middlewares,
+// they are parts of the remote proxy of dev handler because they work on responses of kube-apiserver
+// and depend on its cache manager, dev handler registers "resourcecache" for serving cached lists instead.
+const (
+	RequestInfoMiddleware    = "requestinfo"
+	ComponentMiddleware      = "component"
+	AuthenticationMiddleware = "authentication"
+	AuthorizationMiddleware  = "authorization"
+	LoggingMiddleware        = "logging"
+	MetricsMiddleware        = "metrics"
+	MaxInflightMiddleware    = "maxinflight"
+	AuditMiddleware          = "audit"
+	RequestIDMiddleware      = "requestid"
+)
+
+func init() {
+	RegisterMiddleware(RequestInfoMiddleware, newRequestInfoMiddleware)
+	RegisterMiddleware(ComponentMiddleware, newComponentMiddleware)
+	RegisterMiddleware(AuthenticationMiddleware, newAuthenticationMiddleware)
+	RegisterMiddleware(AuthorizationMiddleware, newAuthorizationMiddleware)
+	RegisterMiddleware(LoggingMiddleware, newLoggingMiddleware)
+	RegisterMiddleware(MetricsMiddleware, newMetricsMiddleware)
+	RegisterMiddleware(MaxInflightMiddleware, newMaxInflightMiddleware)
+	RegisterMiddleware(AuditMiddleware, newAuditMiddleware)
+	RegisterMiddleware(RequestIDMiddleware, newRequestIDMiddleware)
+}
+
+// newRequestInfoMiddleware inject request info and request class into request context,
+// a BadRequest Status is returned for malformed requests.
+func newRequestInfoMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	return func(handler http.Handler) http.Handler {
+		return util.WithRequestClassification(handler, ctx.Classifier)
+	}, nil
+}
+
+// newComponentMiddleware inject client component into request context
+func newComponentMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	return util.WithRequestClientComponent, nil
+}
+
+// newAuthenticationMiddleware authenticate callers when auth is enabled
+func newAuthenticationMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	if !ctx.Cfg.EnableAuth {
+		return nil, nil
+	}
+	client, err := ctx.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+	authn := auth.NewAuthenticator(client, ctx.IsHealthy)
+	return func(handler http.Handler) http.Handler {
+		return auth.WithAuthentication(handler, authn)
+	}, nil
+}
+
+// newAuthorizationMiddleware authorize requests of authenticated callers when auth is enabled
+func newAuthorizationMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	if !ctx.Cfg.EnableAuth {
+		return nil, nil
+	}
+	client, err := ctx.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+	authz := auth.NewAuthorizer(client, ctx.IsHealthy)
+	return func(handler http.Handler) http.Handler {
+		return auth.WithAuthorization(handler, authz, ctx.Classifier)
+	}, nil
+}
+
+// newRequestIDMiddleware inject request id into request context, so logs of a request can be correlated
+func newRequestIDMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	return logs.WithRequestIDHandler, nil
+}
+
+// newLoggingMiddleware write structured access logs, failed requests are always logged,
+// others are logged when verbosity of proxy subsystem is 3 or higher, and they are sampled by verb and resource.
+func newLoggingMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req = req.WithContext(util.WithServingSourceRecorder(req.Context()))
+			start := time.Now()
+			rw := newStatusRecorder(w)
+			handler.ServeHTTP(rw, req)
+
+			logger := logs.FromContext(req.Context(), logs.Proxy)
+			v := logger.V(0)
+			if rw.status < http.StatusInternalServerError {
+				v = logger.V(3)
+				if !v.Enabled() {
+					return
+				}
+				v = v.Sampled(accessLogKey(req))
+			}
+			comp, _ := util.ClientComponentFrom(req.Context())
+			source, _ := util.ServingSourceFrom(req.Context())
+			v.InfoS("Request completed", "verb", req.Method, "uri", req.URL.RequestURI(), "component", comp,
+				"source", source, "status", rw.status, "bytes", rw.bytes, "latency", time.Since(start))
+		})
+	}, nil
+}
+
+// accessLogKey access logs are sampled by verb and resource
+func accessLogKey(req *http.Request) string {
+	if info, ok := apirequest.RequestInfoFrom(req.Context()); ok && info.IsResourceRequest {
+		return info.Verb + "/" + info.Resource
+	}
+	return req.Method
+}
+
+// newMaxInflightMiddleware limit in-flight requests except for long running ones, 429 is returned when the limit is reached
+func newMaxInflightMiddleware(ctx *MiddlewareContext) (Middleware, error) {
+	limit := ctx.Cfg.MaxRequestsInflight
+	if limit <= 0 {
+		return nil, nil
+	}
+	inflight := make(chan struct{}, limit)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if class, ok := util.RequestClassFrom(req.Context()); ok && class.IsLongRunning {
+				handler.ServeHTTP(w, req)
+				return
+			}
+
+			select {
+			case inflight <- struct{}{}:
+				defer func() { <-inflight }()
+				handler.ServeHTTP(w, req)
+			default:
+				logs.FromContext(req.Context(), logs.Proxy).V(2).Sampled("maxinflight").InfoS("Too many requests, reject it",
+					"verb", req.Method, "uri", req.URL.RequestURI())
+				w.Header().Set("Retry-After", strconv.Itoa(1))
+				util.WriteErrStatus(w, apierrors.NewTooManyRequests("too many requests, please try again later", 1))
+			}
+		})
+	}, nil
+}
+
+// statusRecorder record status code and size of response, Flush and Hijack are passed through
+// so that watches and upgraded connections still work.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijack")
+	}
+	return h.Hijack()
+}
@@ -1,42 +1,68 @@
 package proxy
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"k8s.io/klog/v2"
 	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
 
 	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
 )
 
 var (
 	proxyHandlerFactories = map[string]HandlerFactory{}
+	// activeFactory factory of the proxy handler in use
+	activeFactory HandlerFactory
 )
 
+// Register register a handler factory with a unique name, it panics if the name is registered already
 func Register(name string, factory HandlerFactory) {
 	if _, ok := proxyHandlerFactories[name]; ok {
-		klog.Infof("proxy handler %s already has been registered", name)
-		return
+		panic(fmt.Sprintf("proxy handler %s already has been registered", name))
 	}
 
 	klog.Infof("handler factory %s is registered successfully", name)
 	proxyHandlerFactories[name] = factory
 }
 
+// ListHandlers return sorted names of registered handler factories
+func ListHandlers() []string {
+	names := make([]string, 0, len(proxyHandlerFactories))
+	for name := range proxyHandlerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProxyHandler init the proxy handler selected by cfg.Handler
 func GetProxyHandler(cfg *config.EdgeProxyConfiguration, stopCh <-chan struct{}) (http.Handler, error) {
-	if cfg.EnableSampleHandler {
-		if sampleFactory, ok := proxyHandlerFactories["sample"]; ok {
-			return sampleFactory.Init(cfg, stopCh)
-		}
-		return nil, errors.New("sample proxy handler is not found")
+	factory, ok := proxyHandlerFactories[cfg.Handler]
+	if !ok {
+		return nil, fmt.Errorf("proxy handler %q is not registered, registered handlers: %s",
+			cfg.Handler, strings.Join(ListHandlers(), ","))
 	}
 
-	for name, factory := range proxyHandlerFactories {
-		klog.Infof("get a proxy handler from %s", name)
-		if name != "sample" {
-			return factory.Init(cfg, stopCh)
-		}
+	klog.Infof("get a proxy handler from %s", cfg.Handler)
+	activeFactory = factory
+	return factory.Init(cfg, stopCh)
+}
+
+// Drain drain the proxy handler in use if it implements Drainer
+func Drain(ctx context.Context) error {
+	if d, ok := activeFactory.(Drainer); ok {
+		return d.Drain(ctx)
 	}
+	return nil
+}
 
-	return nil, fmt.Errorf("no proxy handler is prepared")
+// GetInspector return the proxy handler in use if it implements Inspector, otherwise nil is returned
+func GetInspector() Inspector {
+	if i, ok := activeFactory.(Inspector); ok {
+		return i
+	}
+	return nil
 }
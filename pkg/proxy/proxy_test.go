@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+type fakeFactory struct {
+	inited bool
+}
+
+func (f *fakeFactory) Init(cfg *config.EdgeProxyConfiguration, stopCh <-chan struct{}) (http.Handler, error) {
+	f.inited = true
+	return http.NotFoundHandler(), nil
+}
+
+func TestGetProxyHandler(t *testing.T) {
+	b, a := &fakeFactory{}, &fakeFactory{}
+	Register("fake-b", b)
+	Register("fake-a", a)
+	defer func() {
+		delete(proxyHandlerFactories, "fake-a")
+		delete(proxyHandlerFactories, "fake-b")
+	}()
+
+	if got := ListHandlers(); !reflect.DeepEqual(got, []string{"fake-a", "fake-b"}) {
+		t.Errorf("ListHandlers = %v, want sorted names", got)
+	}
+
+	if _, err := GetProxyHandler(&config.EdgeProxyConfiguration{Handler: "fake-b"}, nil); err != nil {
+		t.Fatalf("GetProxyHandler err: %v", err)
+	}
+	if !b.inited || a.inited {
+		t.Errorf("only the selected handler should be inited")
+	}
+
+	_, err := GetProxyHandler(&config.EdgeProxyConfiguration{Handler: "unknown"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "fake-a,fake-b") {
+		t.Errorf("expect error with registered handlers, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("register a handler twice should panic")
+		}
+	}()
+	Register("fake-a", &fakeFactory{})
+}
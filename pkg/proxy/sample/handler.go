@@ -8,6 +8,7 @@ import (
 
 	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util"
 )
 
 func init() {
@@ -25,7 +26,13 @@ func (sf *sampleFactory) Init(cfg *config.EdgeProxyConfiguration, stopCh <-chan
 	reverseProxy.FlushInterval = -1
 	reverseProxy.ErrorHandler = errorHandler
 
-	return reverseProxy, nil
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		util.SetServingSource(req.Context(), util.SourceRemote)
+		reverseProxy.ServeHTTP(rw, req)
+	})
+
+	// reuse middlewares like auth, logging and metrics of edge proxy
+	return proxy.BuildHandlerChain(handler, cfg.Middlewares, &proxy.MiddlewareContext{Cfg: cfg})
 }
 
 func errorHandler(rw http.ResponseWriter, req *http.Request, err error) {
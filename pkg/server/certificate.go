@@ -0,0 +1,276 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+)
+
+// define file names of self managed certificates, they are stored under {DiskCachePath}/pki
+const (
+	pkiDir             = "pki"
+	caCertFileName     = "ca.crt"
+	caKeyFileName      = "ca.key"
+	servingCertName    = "edge-proxy-serving.crt"
+	servingKeyName     = "edge-proxy-serving.key"
+	kubeConfigFileName = "edge-proxy.kubeconfig"
+)
+
+// define validity of self managed certificates
+const (
+	caValidity      = 10 * 365 * 24 * time.Hour
+	servingValidity = 365 * 24 * time.Hour
+	// servingRenewBefore serving certificate is renewed when it's going to expire in this duration
+	servingRenewBefore = 30 * 24 * time.Hour
+	// certCheckInterval interval for checking certificate files are changed or not
+	certCheckInterval = 10 * time.Second
+)
+
+// certificateManager provide serving certificate for proxy server,
+// certificate is reloaded when files are rotated, and self managed certificate is renewed before it expires.
+type certificateManager struct {
+	sync.Mutex
+	certFile string
+	keyFile  string
+	// selfManaged certificate is signed by the self signed ca under pkiPath
+	selfManaged bool
+	pkiPath     string
+	hosts       []string
+
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// newCertificateManager create a certificateManager, if certFile and keyFile are empty,
+// a self signed ca and a serving certificate for hosts will be created under pkiPath.
+func newCertificateManager(certFile, keyFile, pkiPath string, hosts []string) (*certificateManager, error) {
+	m := &certificateManager{
+		certFile: certFile,
+		keyFile:  keyFile,
+		pkiPath:  pkiPath,
+		hosts:    hosts,
+	}
+	if certFile == "" && keyFile == "" {
+		m.selfManaged = true
+		m.certFile = filepath.Join(pkiPath, servingCertName)
+		m.keyFile = filepath.Join(pkiPath, servingKeyName)
+		if err := m.ensureSelfManagedCerts(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (m *certificateManager) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if time.Since(m.lastCheck) > certCheckInterval {
+		m.lastCheck = time.Now()
+		if m.selfManaged && m.cert != nil && m.cert.Leaf != nil &&
+			time.Now().Add(servingRenewBefore).After(m.cert.Leaf.NotAfter) {
+			if err := m.writeServingCert(); err != nil {
+				klog.Errorf("could not renew serving certificate, %v", err)
+			}
+		}
+		if err := m.reloadIfChanged(); err != nil {
+			// keep serving with the current certificate
+			klog.Errorf("could not reload serving certificate, %v", err)
+		}
+	}
+	return m.cert, nil
+}
+
+// reloadIfChanged reload certificate when modification time of files are changed
+func (m *certificateManager) reloadIfChanged() error {
+	certInfo, err := os.Stat(m.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(m.keyFile)
+	if err != nil {
+		return err
+	}
+	if certInfo.ModTime().Equal(m.certMod) && keyInfo.ModTime().Equal(m.keyMod) {
+		return nil
+	}
+	if err = m.reload(); err != nil {
+		return err
+	}
+	klog.Infof("serving certificate %s is reloaded", m.certFile)
+	return nil
+}
+
+// reload load certificate and key from files
+func (m *certificateManager) reload() error {
+	certInfo, err := os.Stat(m.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(m.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load certificate %s, %w", m.certFile, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return err
+	}
+
+	m.cert = &cert
+	m.certMod = certInfo.ModTime()
+	m.keyMod = keyInfo.ModTime()
+	return nil
+}
+
+// ensureSelfManagedCerts create ca and serving certificate if they are not found
+func (m *certificateManager) ensureSelfManagedCerts() error {
+	if err := os.MkdirAll(m.pkiPath, 0700); err != nil {
+		return err
+	}
+
+	caCertPath := filepath.Join(m.pkiPath, caCertFileName)
+	caKeyPath := filepath.Join(m.pkiPath, caKeyFileName)
+	if ok, _ := certutil.CanReadCertAndKey(caCertPath, caKeyPath); !ok {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		caCert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: "edge-proxy-ca"}, key)
+		if err != nil {
+			return err
+		}
+		// NewSelfSignedCACert create a ca with 10 years validity
+		if err = writeCertAndKey(caCertPath, caKeyPath, caCert, key); err != nil {
+			return err
+		}
+		klog.Infof("self signed ca is created in %s", caCertPath)
+	}
+
+	if ok, _ := certutil.CanReadCertAndKey(m.certFile, m.keyFile); !ok {
+		return m.writeServingCert()
+	}
+	return nil
+}
+
+// writeServingCert create a serving certificate signed by self signed ca
+func (m *certificateManager) writeServingCert() error {
+	caCerts, err := certutil.CertsFromFile(filepath.Join(m.pkiPath, caCertFileName))
+	if err != nil {
+		return err
+	}
+	caKey, err := keyutil.PrivateKeyFromFile(filepath.Join(m.pkiPath, caKeyFileName))
+	if err != nil {
+		return err
+	}
+	signer, ok := caKey.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("ca key is not a signer")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "edge-proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(servingValidity),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range m.hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if host != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCerts[0], key.Public(), signer)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	if err = writeCertAndKey(m.certFile, m.keyFile, cert, key); err != nil {
+		return err
+	}
+	klog.Infof("serving certificate is created in %s, expires at %v", m.certFile, cert.NotAfter)
+	return nil
+}
+
+// writeCertAndKey write certificate and private key in pem format
+func writeCertAndKey(certPath, keyPath string, cert *x509.Certificate, key crypto.PrivateKey) error {
+	keyData, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return err
+	}
+	certData, err := certutil.EncodeCertificates(cert)
+	if err != nil {
+		return err
+	}
+	// write key first, so certificate is always reloaded with the matched key
+	if err = keyutil.WriteKey(keyPath, keyData); err != nil {
+		return err
+	}
+	return certutil.WriteCert(certPath, certData)
+}
+
+// writeKubeConfig write a kubeconfig for clients of proxy server, which trust the ca of serving certificate
+func writeKubeConfig(file, server, caFile string) error {
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+
+	c := clientcmdapi.NewConfig()
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = server
+	cluster.CertificateAuthorityData = caData
+	c.Clusters["default-cluster"] = cluster
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = "default-cluster"
+	context.Namespace = "default"
+	context.AuthInfo = "default-auth"
+	c.AuthInfos["default-auth"] = clientcmdapi.NewAuthInfo()
+
+	c.Contexts["default-context"] = context
+	c.CurrentContext = "default-context"
+
+	return clientcmd.WriteToFile(*c, file)
+}
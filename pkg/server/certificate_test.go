@@ -0,0 +1,84 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestSelfManagedCertificate(t *testing.T) {
+	pkiPath := filepath.Join(t.TempDir(), pkiDir)
+	m, err := newCertificateManager("", "", pkiPath, []string{"127.0.0.1", "localhost"})
+	if err != nil {
+		t.Fatalf("newCertificateManager err: %v", err)
+	}
+	if !m.selfManaged {
+		t.Fatalf("certificate should be self managed")
+	}
+
+	cert, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate err: %v", err)
+	}
+	if err = cert.Leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Errorf("serving certificate should be valid for 127.0.0.1, %v", err)
+	}
+	if err = cert.Leaf.VerifyHostname("localhost"); err != nil {
+		t.Errorf("serving certificate should be valid for localhost, %v", err)
+	}
+
+	kubeConfig := filepath.Join(pkiPath, kubeConfigFileName)
+	if err = writeKubeConfig(kubeConfig, "https://127.0.0.1:10261", filepath.Join(pkiPath, caCertFileName)); err != nil {
+		t.Fatalf("writeKubeConfig err: %v", err)
+	}
+	restCfg, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+	if err != nil {
+		t.Fatalf("load kubeconfig err: %v", err)
+	}
+	if restCfg.Host != "https://127.0.0.1:10261" || len(restCfg.CAData) == 0 {
+		t.Errorf("kubeconfig should contain server and ca, got host %s", restCfg.Host)
+	}
+
+	// certificates are reused after restart
+	m2, err := newCertificateManager("", "", pkiPath, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("newCertificateManager err: %v", err)
+	}
+	if m2.cert.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Errorf("serving certificate should not be recreated")
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	pkiPath := filepath.Join(t.TempDir(), pkiDir)
+	m, err := newCertificateManager("", "", pkiPath, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("newCertificateManager err: %v", err)
+	}
+	old, _ := m.GetCertificate(nil)
+
+	// rotate serving certificate
+	if err = m.writeServingCert(); err != nil {
+		t.Fatalf("writeServingCert err: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{m.certFile, m.keyFile} {
+		if err = os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("chtimes err: %v", err)
+		}
+	}
+
+	// certificate is not reloaded before check interval
+	if cert, _ := m.GetCertificate(nil); cert != old {
+		t.Errorf("certificate should not be reloaded before check interval")
+	}
+
+	m.lastCheck = time.Time{}
+	cert, _ := m.GetCertificate(nil)
+	if cert.Leaf.SerialNumber.Cmp(old.Leaf.SerialNumber) == 0 {
+		t.Errorf("certificate should be reloaded after rotation")
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+)
+
+// debugHandler serves read-only introspection of proxy handler on stub server,
+// cached lists can be evicted by callers with the debug token.
+type debugHandler struct {
+	inspector proxy.Inspector
+	// token bearer token for eviction, eviction is disabled if it's empty
+	token string
+}
+
+// loadDebugToken read bearer token from file, it's empty if file is not specified
+func loadDebugToken(file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read debug token file, %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("debug token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// registerDebugHandlers registers cache and remote introspection handlers, nothing is registered
+// if the proxy handler in use doesn't implement proxy.Inspector
+func registerDebugHandlers(c *mux.Router, inspector proxy.Inspector, token string) {
+	if inspector == nil {
+		return
+	}
+	h := &debugHandler{inspector: inspector, token: token}
+	c.HandleFunc("/v1/cache", h.listCache).Methods("GET")
+	c.HandleFunc("/v1/cache/{key:.+}", h.getCache).Methods("GET")
+	c.HandleFunc("/v1/cache/{key:.+}", h.evictCache).Methods("DELETE")
+	c.HandleFunc("/v1/remote", h.remoteStatus).Methods("GET")
+}
+
+// listCache returns metadata of cached lists
+func (h *debugHandler) listCache(w http.ResponseWriter, req *http.Request) {
+	entries, err := h.inspector.ListCache()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, entries)
+}
+
+// getCache returns contents of a cached list
+func (h *debugHandler) getCache(w http.ResponseWriter, req *http.Request) {
+	key := mux.Vars(req)["key"]
+	data, err := h.inspector.GetCache(key)
+	if err != nil {
+		writeCacheError(w, key, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// evictCache removes a cached list, the caller should be authenticated by the debug token
+func (h *debugHandler) evictCache(w http.ResponseWriter, req *http.Request) {
+	if h.token == "" {
+		http.Error(w, "cache eviction is disabled, debug-token-file is not set", http.StatusForbidden)
+		return
+	}
+	if !h.authenticated(req) {
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	key := mux.Vars(req)["key"]
+	if err := h.inspector.EvictCache(key); err != nil {
+		writeCacheError(w, key, err)
+		return
+	}
+	logs.For(logs.Server).InfoS("Evict cache", "key", key, "remoteAddr", req.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// remoteStatus returns health status and its history of remote servers
+func (h *debugHandler) remoteStatus(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, h.inspector.RemoteStatus())
+}
+
+// authenticated check bearer token of request is the debug token
+func (h *debugHandler) authenticated(req *http.Request) bool {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// writeCacheError write status for errors of cache access, unknown keys are not found
+func writeCacheError(w http.ResponseWriter, key string, err error) {
+	if errors.Is(err, storage.ErrStorageNotFound) {
+		http.Error(w, fmt.Sprintf("cache %s is not found", key), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrStorageAccessConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// writeJSON write v as json response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logs.For(logs.Server).ErrorS(err, "Could not write debug response")
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/storage"
+)
+
+type fakeInspector struct {
+	cache map[string][]byte
+}
+
+func (f *fakeInspector) ListCache() (interface{}, error) {
+	keys := []string{}
+	for key := range f.cache {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (f *fakeInspector) GetCache(key string) ([]byte, error) {
+	data, ok := f.cache[key]
+	if !ok {
+		return nil, storage.ErrStorageNotFound
+	}
+	return data, nil
+}
+
+func (f *fakeInspector) EvictCache(key string) error {
+	if _, ok := f.cache[key]; !ok {
+		return storage.ErrStorageNotFound
+	}
+	delete(f.cache, key)
+	return nil
+}
+
+func (f *fakeInspector) RemoteStatus() interface{} {
+	return []string{"healthy"}
+}
+
+func TestDebugHandlers(t *testing.T) {
+	key := "kubelet/pods/default/consistency"
+	inspector := &fakeInspector{cache: map[string][]byte{key: []byte(`{"items":[]}`)}}
+	r := mux.NewRouter()
+	registerDebugHandlers(r, inspector, "secret")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+		body   string
+	}{
+		{"list", "GET", "/v1/cache", "", http.StatusOK, `["` + key + `"]`},
+		{"get", "GET", "/v1/cache/" + key, "", http.StatusOK, `{"items":[]}`},
+		{"get unknown", "GET", "/v1/cache/kubelet/nodes/consistency", "", http.StatusNotFound, ""},
+		{"remote", "GET", "/v1/remote", "", http.StatusOK, `["healthy"]`},
+		{"evict without token", "DELETE", "/v1/cache/" + key, "", http.StatusUnauthorized, ""},
+		{"evict with wrong token", "DELETE", "/v1/cache/" + key, "wrong", http.StatusUnauthorized, ""},
+		{"evict", "DELETE", "/v1/cache/" + key, "secret", http.StatusNoContent, ""},
+		{"get evicted", "GET", "/v1/cache/" + key, "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expect status %d, got %d", tt.name, tt.status, w.Code)
+		}
+		body, _ := io.ReadAll(w.Body)
+		if tt.body != "" && strings.TrimSpace(string(body)) != tt.body {
+			t.Errorf("%s: expect body %s, got %s", tt.name, tt.body, body)
+		}
+	}
+}
+
+func TestDebugHandlersEvictionDisabled(t *testing.T) {
+	key := "kubelet/pods/default/consistency"
+	inspector := &fakeInspector{cache: map[string][]byte{key: []byte(`{}`)}}
+	r := mux.NewRouter()
+	registerDebugHandlers(r, inspector, "")
+
+	req := httptest.NewRequest("DELETE", "/v1/cache/"+key, nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expect status %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if _, ok := inspector.cache[key]; !ok {
+		t.Errorf("expect cache is not evicted")
+	}
+}
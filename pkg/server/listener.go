@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+// unixSchemePrefix prefix of unix domain socket address, like unix:///run/edge-proxy.sock
+const unixSchemePrefix = "unix://"
+
+// proxyListener listener of proxy server
+type proxyListener struct {
+	net.Listener
+	addr string
+	// unix listener is served without tls, access is controlled by file mode and owner of socket
+	unix bool
+}
+
+// splitProxyServerAddrs split comma separated addresses of proxy server
+func splitProxyServerAddrs(addrs string) []string {
+	var result []string
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// firstTCPAddr return the first tcp address of proxy server, empty if proxy server only listen on unix sockets
+func firstTCPAddr(addrs string) string {
+	for _, addr := range splitProxyServerAddrs(addrs) {
+		if !strings.HasPrefix(addr, unixSchemePrefix) {
+			return addr
+		}
+	}
+	return ""
+}
+
+// newProxyListeners listen on all addresses of proxy server
+func newProxyListeners(cfg *config.EdgeProxyConfiguration) ([]*proxyListener, error) {
+	var listeners []*proxyListener
+	for _, addr := range splitProxyServerAddrs(cfg.EdgeProxyServerAddr) {
+		var (
+			l   *proxyListener
+			err error
+		)
+		if strings.HasPrefix(addr, unixSchemePrefix) {
+			l, err = listenUnix(strings.TrimPrefix(addr, unixSchemePrefix), cfg)
+		} else {
+			var nl net.Listener
+			nl, err = net.Listen("tcp", addr)
+			l = &proxyListener{Listener: nl, addr: addr}
+		}
+		if err != nil {
+			for i := range listeners {
+				listeners[i].Close()
+			}
+			return nil, fmt.Errorf("could not listen on %s, %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no address is set for proxy server")
+	}
+	return listeners, nil
+}
+
+// listenUnix listen on unix domain socket, and set file mode and owner of the socket
+func listenUnix(path string, cfg *config.EdgeProxyConfiguration) (*proxyListener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, cfg.UnixSocketMode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if cfg.UnixSocketUID >= 0 || cfg.UnixSocketGID >= 0 {
+		if err = os.Chown(path, cfg.UnixSocketUID, cfg.UnixSocketGID); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	klog.Infof("proxy server listen on unix socket %s, mode: %v", path, cfg.UnixSocketMode)
+
+	return &proxyListener{Listener: l, addr: unixSchemePrefix + path, unix: true}, nil
+}
+
+// removeStaleSocket remove socket left by last run, other files are never removed,
+// so a mistyped address can not delete them.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	return os.Remove(path)
+}
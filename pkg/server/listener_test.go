@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+func TestNewProxyListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "run", "edge-proxy.sock")
+	cfg := &config.EdgeProxyConfiguration{
+		EdgeProxyServerAddr: "127.0.0.1:0, unix://" + sock,
+		UnixSocketMode:      0600,
+		UnixSocketUID:       -1,
+		UnixSocketGID:       -1,
+	}
+	listeners, err := newProxyListeners(cfg)
+	if err != nil {
+		t.Fatalf("newProxyListeners err: %v", err)
+	}
+	if len(listeners) != 2 || listeners[0].unix || !listeners[1].unix {
+		t.Fatalf("expect a tcp and a unix listener, got %v", listeners)
+	}
+
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("stat socket err: %v", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode of socket: %v", fi.Mode())
+	}
+
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	defer s.Close()
+	for _, l := range listeners {
+		go s.Serve(l)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	clients := map[string]*http.Client{
+		"http://" + listeners[0].Addr().String(): http.DefaultClient,
+		"http://unix":                            unixClient,
+	}
+	for u, client := range clients {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("get %s err: %v", u, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(data) != "ok" {
+			t.Errorf("get %s, expect ok, got %s", u, string(data))
+		}
+	}
+
+	// stale socket is removed when listening again
+	s.Close()
+	listeners, err = newProxyListeners(cfg)
+	if err != nil {
+		t.Fatalf("listen again err: %v", err)
+	}
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+func TestListenUnixNotSocket(t *testing.T) {
+	cfg := &config.EdgeProxyConfiguration{UnixSocketMode: 0600, UnixSocketUID: -1, UnixSocketGID: -1}
+
+	// a stale socket is replaced
+	sock := filepath.Join(t.TempDir(), "stale.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	pl, err := listenUnix(sock, cfg)
+	if err != nil {
+		t.Fatalf("expect stale socket is replaced, got %v", err)
+	}
+	pl.Close()
+
+	// regular files and symlinks are kept
+	file := filepath.Join(t.TempDir(), "data")
+	if err = os.WriteFile(file, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err = os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{file, link} {
+		if _, err = listenUnix(path, cfg); err == nil {
+			t.Errorf("expect error for listening on %s", path)
+		}
+		if _, err = os.Lstat(path); err != nil {
+			t.Errorf("expect %s is kept, got %v", path, err)
+		}
+	}
+}
@@ -1,67 +1,214 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 
+	certutil "k8s.io/client-go/util/cert"
 	"k8s.io/klog/v2"
 
 	"github.com/gorilla/mux"
-	//"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
 	"code.aliyun.com/openyurt/edge-proxy/pkg/profile"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/proxy"
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
 )
 
 // Server is an interface for providing http service for edge proxy
 type Server interface {
-	Run()
+	// Run serve until stopCh is closed or a server exits unexpectedly
+	Run(stopCh <-chan struct{}) error
+	// Shutdown shut down servers gracefully before ctx is done
+	Shutdown(ctx context.Context) error
 }
 
 // edgeProxyServer includes stubServer and proxyServer,
-// and stubServer handles requests by edge proxy itself, like profiling, metrics, healthz
+// and stubServer handles requests by edge proxy itself, like profiling, metrics, healthz, cache introspection
 // and proxyServer does not handle requests locally and proxy requests to kube-apiserver
 type edgeProxyServer struct {
 	stubServer  *http.Server
 	proxyServer *http.Server
+	// proxyListeners tcp and unix listeners of proxyServer, they share the same handler
+	proxyListeners []*proxyListener
+	// cancelLongRunning cancel context of watch requests when shutting down, so watch streams are closed
+	cancelLongRunning context.CancelFunc
 }
 
 // NewEdgeProxyServer creates a Server object
 func NewEdgeProxyServer(cfg *config.EdgeProxyConfiguration,
 	proxyHandler http.Handler) (Server, error) {
+	debugToken, err := loadDebugToken(cfg.DebugTokenFile)
+	if err != nil {
+		return nil, err
+	}
 	edgeMux := mux.NewRouter()
 	// 健康检查，metrics 相关
 	registerHandlers(edgeMux)
+	// cache and remote introspection
+	registerDebugHandlers(edgeMux, proxy.GetInspector(), debugToken)
 	stubServer := &http.Server{
 		Addr:           cfg.BindAddr,
 		Handler:        edgeMux,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	var tlsConfig *tls.Config
+	if cfg.EnableHTTPS {
+		if tlsConfig, err = prepareTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	listeners, err := newProxyListeners(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	longRunningCtx, cancelLongRunning := context.WithCancel(context.Background())
 	proxyServer := &http.Server{
-		Addr:    cfg.EdgeProxyServerAddr,
-		Handler: proxyHandler,
+		Handler:   withLongRunningCancel(proxyHandler, longRunningCtx),
+		TLSConfig: tlsConfig,
 	}
 
 	return &edgeProxyServer{
-		stubServer:  stubServer,
-		proxyServer: proxyServer,
+		stubServer:        stubServer,
+		proxyServer:       proxyServer,
+		proxyListeners:    listeners,
+		cancelLongRunning: cancelLongRunning,
 	}, nil
 }
 
-// Run will start stubServer and proxy server
-func (s *edgeProxyServer) Run() {
+// Run will start stubServer and proxy server, and return when stopCh is closed or a server exits,
+// servers should be shut down by Shutdown after it returns.
+func (s *edgeProxyServer) Run(stopCh <-chan struct{}) error {
+	errCh := make(chan error, len(s.proxyListeners)+1)
 	go func() {
-		err := s.stubServer.ListenAndServe()
+		errCh <- s.stubServer.ListenAndServe()
+	}()
+
+	for i := range s.proxyListeners {
+		go func(l *proxyListener) {
+			klog.Infof("proxy server serve on %s", l.addr)
+			if s.proxyServer.TLSConfig != nil && !l.unix {
+				// certificate is provided by TLSConfig.GetCertificate
+				errCh <- s.proxyServer.ServeTLS(l, "", "")
+				return
+			}
+			errCh <- s.proxyServer.Serve(l)
+		}(s.proxyListeners[i])
+	}
+
+	select {
+	case <-stopCh:
+		return nil
+	case err := <-errCh:
+		klog.Errorf("server exited unexpectedly, %v", err)
+		return err
+	}
+}
+
+// Shutdown stop accepting new requests, close watch streams, and wait in-flight requests until ctx is done
+func (s *edgeProxyServer) Shutdown(ctx context.Context) error {
+	// watches never become idle, close them so Shutdown can finish
+	s.cancelLongRunning()
+
+	var errs []error
+	if err := s.proxyServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown proxy server, %w", err))
+	}
+	if err := s.stubServer.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("shutdown stub server, %w", err))
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	klog.Infof("servers are shut down")
+	return nil
+}
+
+// withLongRunningCancel cancel context of watch requests when ctx is done
+func withLongRunningCancel(handler http.Handler, ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isWatchRequest(req) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		reqCtx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-reqCtx.Done():
+			}
+		}()
+		handler.ServeHTTP(w, req.WithContext(reqCtx))
+	})
+}
+
+// isWatchRequest check request is a watch or not, like ?watch=true or /api/v1/watch/pods
+func isWatchRequest(req *http.Request) bool {
+	if w := req.URL.Query().Get("watch"); w == "true" || w == "1" {
+		return true
+	}
+	return strings.Contains(req.URL.Path, "/watch/")
+}
+
+// prepareTLSConfig prepare tls config for proxy server, a self signed ca and serving certificate
+// are created under DiskCachePath when certificate files are not specified,
+// and a kubeconfig trusting the ca is written for clients.
+func prepareTLSConfig(cfg *config.EdgeProxyConfiguration) (*tls.Config, error) {
+	hosts := []string{"127.0.0.1", "localhost"}
+	for _, addr := range splitProxyServerAddrs(cfg.EdgeProxyServerAddr) {
+		if strings.HasPrefix(addr, unixSchemePrefix) {
+			continue
+		}
+		host, _, err := net.SplitHostPort(addr)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-	}()
+		if host != "" && host != "127.0.0.1" && host != "localhost" {
+			hosts = append(hosts, host)
+		}
+	}
 
-	err := s.proxyServer.ListenAndServe()
+	pkiPath := filepath.Join(cfg.DiskCachePath, pkiDir)
+	m, err := newCertificateManager(cfg.TLSCertFile, cfg.TLSPrivateKeyFile, pkiPath, hosts)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("could not prepare serving certificate, %w", err)
+	}
+	if addr := firstTCPAddr(cfg.EdgeProxyServerAddr); m.selfManaged && addr != "" {
+		kubeConfig := filepath.Join(pkiPath, kubeConfigFileName)
+		err = writeKubeConfig(kubeConfig, "https://"+addr, filepath.Join(pkiPath, caCertFileName))
+		if err != nil {
+			return nil, fmt.Errorf("could not write kubeconfig for proxy server, %w", err)
+		}
+		klog.Infof("kubeconfig for proxy server is written to %s", kubeConfig)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
 	}
+	if cfg.ClientCAFile != "" {
+		pool, err := certutil.NewPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client ca file, %w", err)
+		}
+		// client certificates are verified if given, and used for authentication
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
 }
 
 // registerHandler registers handlers for edge proxy server, like profiling, healthz.
@@ -73,20 +220,12 @@ func registerHandlers(c *mux.Router) {
 	profile.Install(c)
 
 	// register handler for metrics
-	//c.Handle("/metrics", &wrapHandler{})
+	c.Handle("/metrics", promhttp.Handler())
 }
 
-//type wrapHandler struct {
-//}
-//
-//func (wrapHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-//	klog.Infof("enter metrics")
-//	promhttp.Handler().ServeHTTP(rw, req)
-//}
-
 // healthz returns ok for healthz request
-func healthz(w http.ResponseWriter, _ *http.Request) {
-	klog.Infof("enter healthz")
+func healthz(w http.ResponseWriter, req *http.Request) {
+	logs.For(logs.Server).V(5).Sampled("healthz").InfoS("Serve healthz", "remoteAddr", req.RemoteAddr)
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "OK")
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"code.aliyun.com/openyurt/edge-proxy/cmd/edge-proxy/app/config"
+)
+
+func TestRunShutdown(t *testing.T) {
+	watchStarted := make(chan struct{})
+	slowStarted := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if isWatchRequest(req) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			close(watchStarted)
+			<-req.Context().Done()
+			return
+		}
+		close(slowStarted)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "ok")
+	})
+
+	cfg := &config.EdgeProxyConfiguration{
+		BindAddr:             "127.0.0.1:0",
+		EdgeProxyServerAddr:  "127.0.0.1:0",
+		ShutdownDrainTimeout: 5 * time.Second,
+	}
+	s, err := NewEdgeProxyServer(cfg, handler)
+	if err != nil {
+		t.Fatalf("NewEdgeProxyServer err: %v", err)
+	}
+	addr := "http://" + s.(*edgeProxyServer).proxyListeners[0].Addr().String()
+
+	stopCh := make(chan struct{})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(stopCh)
+	}()
+
+	watchDone := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(addr + "/api/v1/pods?watch=true")
+		if err != nil {
+			watchDone <- err
+			return
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		watchDone <- err
+	}()
+	<-watchStarted
+
+	slowDone := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(addr + "/api/v1/pods")
+		if err != nil {
+			slowDone <- err.Error()
+			return
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		slowDone <- string(data)
+	}()
+	<-slowStarted
+
+	close(stopCh)
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Run should return nil when stopCh is closed, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Run does not return after stopCh is closed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	defer cancel()
+	if err = s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown should return nil after graceful shutdown, got %v", err)
+	}
+	if got := <-slowDone; got != "ok" {
+		t.Errorf("in-flight request should be finished, got %s", got)
+	}
+	select {
+	case <-watchDone:
+	case <-time.After(time.Second):
+		t.Errorf("watch stream should be closed when shutting down")
+	}
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanRecord a finished span written as a json line
+type spanRecord struct {
+	TraceID      string    `json:"traceID"`
+	SpanID       string    `json:"spanID"`
+	ParentSpanID string    `json:"parentSpanID,omitempty"`
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	StartTime    time.Time `json:"startTime"`
+	// DurationMillis duration of span in milliseconds
+	DurationMillis float64                `json:"durationMs"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+	Status         string                 `json:"status,omitempty"`
+	StatusMessage  string                 `json:"statusMessage,omitempty"`
+}
+
+// fileExporter write spans to a file as json lines, so traces can be inspected without a collector
+type fileExporter struct {
+	sync.Mutex
+	w io.WriteCloser
+}
+
+// newFileExporter create an exporter appending to path, "-" means stdout
+func newFileExporter(path string) (*fileExporter, error) {
+	if path == "-" {
+		return &fileExporter{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{w: f}, nil
+}
+
+// ExportSpans write spans to file
+func (e *fileExporter) ExportSpans(ctx context.Context, spans []*sdktrace.SpanSnapshot) error {
+	e.Lock()
+	defer e.Unlock()
+	for _, s := range spans {
+		data, err := json.Marshal(newSpanRecord(s))
+		if err != nil {
+			return err
+		}
+		if _, err = e.w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown close file, stdout is kept open
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.w == os.Stdout {
+		return nil
+	}
+	return e.w.Close()
+}
+
+func newSpanRecord(s *sdktrace.SpanSnapshot) *spanRecord {
+	r := &spanRecord{
+		TraceID:        s.SpanContext.TraceID().String(),
+		SpanID:         s.SpanContext.SpanID().String(),
+		Name:           s.Name,
+		Kind:           s.SpanKind.String(),
+		StartTime:      s.StartTime,
+		DurationMillis: float64(s.EndTime.Sub(s.StartTime).Microseconds()) / 1000,
+		StatusMessage:  s.StatusMessage,
+	}
+	if s.Parent.IsValid() {
+		r.ParentSpanID = s.Parent.SpanID().String()
+	}
+	if s.StatusCode != 0 {
+		r.Status = s.StatusCode.String()
+	}
+	if len(s.Attributes) != 0 {
+		r.Attributes = make(map[string]interface{}, len(s.Attributes))
+		for _, kv := range s.Attributes {
+			r.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+		}
+	}
+	return r
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/projectinfo"
+)
+
+// instrumentationName name of tracer of edge proxy
+const instrumentationName = "code.aliyun.com/openyurt/edge-proxy"
+
+// enabled spans are only created when tracing is enabled by Init,
+// so requests are not slowed down by no-op spans.
+var enabled bool
+
+// Init set up the global tracer provider and W3C trace context propagator, spans are exported to the OTLP/gRPC
+// endpoint of a collector like 127.0.0.1:4317, and written to file as json lines, "-" means stdout.
+// tracing is disabled if both endpoint and file are empty. the returned func flush and stop exporters.
+func Init(endpoint, file string, samplingRatio float64) (func(context.Context) error, error) {
+	if endpoint == "" && file == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(projectinfo.GetProxyName()))),
+	}
+	if endpoint != "" {
+		// collector is expected to be local, so the connection is not secured
+		driver := otlpgrpc.NewDriver(otlpgrpc.WithEndpoint(endpoint), otlpgrpc.WithInsecure())
+		exporter, err := otlp.NewExporter(context.Background(), driver)
+		if err != nil {
+			return nil, fmt.Errorf("could not create otlp exporter for %s, %w", endpoint, err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if file != "" {
+		exporter, err := newFileExporter(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not create span file %s, %w", file, err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled = true
+	klog.Infof("tracing is enabled, endpoint: %q, file: %q, sampling ratio: %v", endpoint, file, samplingRatio)
+	return provider.Shutdown, nil
+}
+
+// Enabled returns whether tracing is enabled
+func Enabled() bool {
+	return enabled
+}
+
+// Start start a span as a child of the span in ctx, the span is not recorded if tracing is disabled
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !enabled {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End record err in span if it's not nil, and end the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject write trace context of ctx into header, so the trace is continued by the receiver like kube-apiserver
+func Inject(ctx context.Context, header http.Header) {
+	if enabled {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	}
+}
+
+// WithServerSpan start a server span for each request, traceparent header of request is used as its parent
+func WithServerSpan(handler http.Handler) http.Handler {
+	if !enabled {
+		return handler
+	}
+	return otelhttp.NewHandler(handler, projectinfo.GetProxyName(), otelhttp.WithSpanNameFormatter(
+		func(operation string, req *http.Request) string {
+			return "HTTP " + req.Method
+		}))
+}
+
+// WithStageSpan start a span named name for a stage of handler chain, like a middleware
+func WithStageSpan(handler http.Handler, name string) http.Handler {
+	if !enabled {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, span := Start(req.Context(), name)
+		defer span.End()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	json "github.com/json-iterator/go"
+)
+
+func TestTracing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Init("", file, 1)
+	if err != nil {
+		t.Fatalf("init tracing err: %v", err)
+	}
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	var upstream http.Header
+	handler := WithServerSpan(WithStageSpan(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, span := Start(req.Context(), "cache QueryCache")
+		End(span, nil)
+
+		upstream = http.Header{}
+		Inject(req.Context(), upstream)
+	}), "handler"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(upstream.Get("traceparent"), traceID) {
+		t.Errorf("traceparent should be propagated, got %q", upstream.Get("traceparent"))
+	}
+
+	if err = shutdown(context.TODO()); err != nil {
+		t.Fatalf("shutdown tracing err: %v", err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("open span file err: %v", err)
+	}
+	defer f.Close()
+
+	spans := map[string]*spanRecord{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s spanRecord
+		if err = json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("decode span err: %v", err)
+		}
+		spans[s.Name] = &s
+	}
+	for _, name := range []string{"HTTP GET", "handler", "cache QueryCache"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("span %s is not exported, got %v", name, spans)
+		}
+		if s.TraceID != traceID {
+			t.Errorf("span %s should be in trace of caller, got %s", name, s.TraceID)
+		}
+	}
+	if spans["HTTP GET"].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("parent of server span should be the caller, got %s", spans["HTTP GET"].ParentSpanID)
+	}
+	if spans["cache QueryCache"].ParentSpanID != spans["handler"].SpanID {
+		t.Errorf("parent of cache span should be the handler span")
+	}
+}
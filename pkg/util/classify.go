@@ -0,0 +1,105 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	genericfilters "k8s.io/apiserver/pkg/server/filters"
+)
+
+// RequestClass classification of a request besides RequestInfo
+type RequestClass struct {
+	// Component client component, like kubelet
+	Component string
+	// GVR resource of request, it's empty for non-resource requests
+	GVR schema.GroupVersionResource
+	// IsWatch request is a watch
+	IsWatch bool
+	// IsLongRunning request is a watch, proxy, exec, attach, log or portforward, which has no deadline
+	IsLongRunning bool
+}
+
+// WithRequestClass returns a copy of parent in which the request class is set
+func WithRequestClass(parent context.Context, class *RequestClass) context.Context {
+	return context.WithValue(parent, ProxyReqClass, class)
+}
+
+// RequestClassFrom returns the request class on the ctx
+func RequestClassFrom(ctx context.Context) (*RequestClass, bool) {
+	class, ok := ctx.Value(ProxyReqClass).(*RequestClass)
+	return class, ok
+}
+
+// RequestClassifier classify requests of proxy server
+type RequestClassifier struct {
+	resolver    apirequest.RequestInfoResolver
+	longRunning apirequest.LongRunningRequestCheck
+}
+
+// NewRequestClassifier create a RequestClassifier with resolver
+func NewRequestClassifier(resolver apirequest.RequestInfoResolver) *RequestClassifier {
+	return &RequestClassifier{
+		resolver: resolver,
+		longRunning: genericfilters.BasicLongRunningRequestCheck(
+			sets.NewString("watch", "proxy"),
+			sets.NewString("attach", "exec", "proxy", "log", "portforward"),
+		),
+	}
+}
+
+// Classify return a copy of req in which RequestInfo and RequestClass are set, they are reused if they are set already.
+// paths which are not recognized are classified as non-resource requests,
+// and a BadRequest error is returned only for malformed requests, like /api/v1/watch or invalid query.
+func (c *RequestClassifier) Classify(req *http.Request) (*http.Request, error) {
+	ctx := req.Context()
+	if _, ok := RequestClassFrom(ctx); ok {
+		return req, nil
+	}
+
+	info, ok := apirequest.RequestInfoFrom(ctx)
+	if !ok {
+		if _, err := url.ParseQuery(req.URL.RawQuery); err != nil {
+			return req, apierrors.NewBadRequest(fmt.Sprintf("invalid query %q, %v", req.URL.RawQuery, err))
+		}
+		var err error
+		info, err = c.resolver.NewRequestInfo(req)
+		if err != nil {
+			return req, apierrors.NewBadRequest(fmt.Sprintf("could not resolve request info, %v", err))
+		}
+		ctx = apirequest.WithRequestInfo(ctx, info)
+	}
+
+	comp, ok := ClientComponentFrom(ctx)
+	if !ok {
+		comp = clientComponent(req)
+		ctx = WithClientComponent(ctx, comp)
+	}
+
+	class := &RequestClass{
+		Component:     comp,
+		IsWatch:       info.Verb == "watch",
+		IsLongRunning: c.longRunning(req, info),
+	}
+	if info.IsResourceRequest {
+		class.GVR = schema.GroupVersionResource{Group: info.APIGroup, Version: info.APIVersion, Resource: info.Resource}
+	}
+	return req.WithContext(WithRequestClass(ctx, class)), nil
+}
+
+// WithRequestClassification classify requests by classifier, a Status is returned for malformed requests
+func WithRequestClassification(handler http.Handler, classifier *RequestClassifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req, err := classifier.Classify(req)
+		if err != nil {
+			WriteErrStatus(w, err)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
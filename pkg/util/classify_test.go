@@ -0,0 +1,64 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestClassify(t *testing.T) {
+	classifier := NewRequestClassifier(&apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	})
+
+	tests := []struct {
+		url         string
+		gvr         schema.GroupVersionResource
+		resource    bool
+		watch       bool
+		longRunning bool
+		badRequest  bool
+	}{
+		{url: "/api/v1/pods", gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, resource: true},
+		{url: "/apis/apps/v1/deployments?watch=true", gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, resource: true, watch: true, longRunning: true},
+		{url: "/api/v1/namespaces/default/pods/foo/log", gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, resource: true, longRunning: true},
+		{url: "/unknown/path/to/something"},
+		{url: "/healthz"},
+		{url: "/api/v1/watch", badRequest: true},
+		{url: "/api/v1/pods?limit=%zz", badRequest: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		req.Header.Set("User-Agent", "kubelet/v1.22.3")
+
+		got, err := classifier.Classify(req)
+		if tt.badRequest {
+			if err == nil {
+				t.Errorf("%s: expect bad request error", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.url, err)
+			continue
+		}
+
+		info, ok := apirequest.RequestInfoFrom(got.Context())
+		if !ok || info.IsResourceRequest != tt.resource {
+			t.Errorf("%s: expect request info with IsResourceRequest=%v, got %#v", tt.url, tt.resource, info)
+		}
+		class, ok := RequestClassFrom(got.Context())
+		if !ok {
+			t.Errorf("%s: request class is not set", tt.url)
+			continue
+		}
+		if class.GVR != tt.gvr || class.IsWatch != tt.watch || class.IsLongRunning != tt.longRunning || class.Component != "kubelet" {
+			t.Errorf("%s: unexpected class %#v", tt.url, class)
+		}
+	}
+}
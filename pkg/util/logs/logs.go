@@ -0,0 +1,225 @@
+package logs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// subsystems of edge proxy, verbosity of each one can be raised separately by SetSubsystemVerbosity
+const (
+	// Proxy handler chain and access log of proxy server
+	Proxy = "proxy"
+	// Remote requests proxied to kube-apiserver and caching of their responses
+	Remote = "remote"
+	// Local requests served locally when kube-apiserver is unhealthy
+	Local = "local"
+	// Cache reads and writes of cache manager
+	Cache = "cache"
+	// Server stub server, like healthz and profiling
+	Server = "server"
+)
+
+// Subsystems names of subsystems
+var Subsystems = []string{Proxy, Remote, Local, Cache, Server}
+
+// RequestIDHeader header of request id, it's kept if set by client, and it's returned in response
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength request ids set by clients longer than it are replaced
+const maxRequestIDLength = 64
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// WithRequestID returns a copy of parent in which the request id is set
+func WithRequestID(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, requestIDKey, id)
+}
+
+// RequestIDFrom returns the request id on the ctx
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok && id != ""
+}
+
+// WithRequestIDHandler inject request id into request context, request id is taken from X-Request-Id header
+// or generated, it's set in the request header so it's forwarded to kube-apiserver, and returned in response header.
+func WithRequestIDHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+			req.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		handler.ServeHTTP(w, req.WithContext(WithRequestID(req.Context(), id)))
+	})
+}
+
+// validRequestID request id should be short and printable, because it's written to logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	lock               sync.RWMutex
+	subsystemVerbosity = map[string]klog.Level{}
+	// the first 5 entries of a key in each second are logged, then every 100th entry, until SetSampling is called
+	samplingInitial    = 5
+	samplingThereafter = 100
+	samplers           = map[string]*sampler{}
+)
+
+// SetSubsystemVerbosity set verbosity of subsystems, logs of a subsystem are enabled
+// if the level is enabled by -v or the verbosity of the subsystem.
+func SetSubsystemVerbosity(levels map[string]int) {
+	lock.Lock()
+	defer lock.Unlock()
+	subsystemVerbosity = make(map[string]klog.Level, len(levels))
+	for name, level := range levels {
+		subsystemVerbosity[name] = klog.Level(level)
+	}
+}
+
+// SetSampling set sampling of hot paths, the first initial entries of a key in each second are logged,
+// then every thereafter-th entry, sampling is disabled if initial is 0, and the rest are dropped if thereafter is 0.
+func SetSampling(initial, thereafter int) {
+	lock.Lock()
+	defer lock.Unlock()
+	samplingInitial, samplingThereafter = initial, thereafter
+	samplers = map[string]*sampler{}
+}
+
+// Logger structured logger of a subsystem, key/values added by WithValues are appended to every entry
+type Logger struct {
+	subsystem string
+	values    []interface{}
+}
+
+// For returns the logger of subsystem
+func For(subsystem string) Logger {
+	return Logger{subsystem: subsystem}
+}
+
+// FromContext returns the logger of subsystem with the request id on ctx
+func FromContext(ctx context.Context, subsystem string) Logger {
+	l := For(subsystem)
+	if id, ok := RequestIDFrom(ctx); ok {
+		l = l.WithValues("requestID", id)
+	}
+	return l
+}
+
+// WithValues returns a copy of l with key/values added
+func (l Logger) WithValues(keysAndValues ...interface{}) Logger {
+	values := make([]interface{}, 0, len(l.values)+len(keysAndValues))
+	l.values = append(append(values, l.values...), keysAndValues...)
+	return l
+}
+
+// V returns a Verbose which is enabled if level is enabled by -v or verbosity of the subsystem
+func (l Logger) V(level klog.Level) Verbose {
+	enabled := klog.V(level).Enabled()
+	if !enabled {
+		lock.RLock()
+		v, ok := subsystemVerbosity[l.subsystem]
+		lock.RUnlock()
+		enabled = ok && v >= level
+	}
+	return Verbose{enabled: enabled, logger: l}
+}
+
+// InfoS log msg with key/values of l, it's always logged
+func (l Logger) InfoS(msg string, keysAndValues ...interface{}) {
+	klog.InfoSDepth(1, msg, l.keysAndValues(keysAndValues)...)
+}
+
+// ErrorS log err and msg with key/values of l
+func (l Logger) ErrorS(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorSDepth(1, err, msg, l.keysAndValues(keysAndValues)...)
+}
+
+func (l Logger) keysAndValues(keysAndValues []interface{}) []interface{} {
+	kvs := make([]interface{}, 0, 2+len(l.values)+len(keysAndValues))
+	kvs = append(kvs, "subsystem", l.subsystem)
+	kvs = append(kvs, l.values...)
+	return append(kvs, keysAndValues...)
+}
+
+// Verbose logger guarded by a verbosity level
+type Verbose struct {
+	enabled bool
+	logger  Logger
+}
+
+// Enabled returns whether logs of the level are enabled
+func (v Verbose) Enabled() bool {
+	return v.enabled
+}
+
+// Sampled returns a copy of v which is disabled if the entry of key is dropped by sampling,
+// it's used for hot paths like logs of every request.
+func (v Verbose) Sampled(key string) Verbose {
+	if v.enabled {
+		v.enabled = sample(v.logger.subsystem+"/"+key, time.Now())
+	}
+	return v
+}
+
+// InfoS log msg with key/values if v is enabled
+func (v Verbose) InfoS(msg string, keysAndValues ...interface{}) {
+	if v.enabled {
+		klog.InfoSDepth(1, msg, v.logger.keysAndValues(keysAndValues)...)
+	}
+}
+
+// sampler count entries of a key in the current second
+type sampler struct {
+	sync.Mutex
+	tick  int64
+	count int
+}
+
+// sample returns whether the entry of key at now should be logged
+func sample(key string, now time.Time) bool {
+	lock.RLock()
+	initial, thereafter := samplingInitial, samplingThereafter
+	s := samplers[key]
+	lock.RUnlock()
+	if initial <= 0 {
+		return true
+	}
+	if s == nil {
+		lock.Lock()
+		if s = samplers[key]; s == nil {
+			s = &sampler{}
+			samplers[key] = s
+		}
+		lock.Unlock()
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if tick := now.Unix(); tick != s.tick {
+		s.tick, s.count = tick, 0
+	}
+	s.count++
+	if s.count <= initial {
+		return true
+	}
+	return thereafter > 0 && (s.count-initial)%thereafter == 0
+}
@@ -0,0 +1,101 @@
+package logs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithRequestIDHandler(t *testing.T) {
+	var got string
+	handler := WithRequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got, _ = RequestIDFrom(req.Context())
+		if h := req.Header.Get(RequestIDHeader); h != got {
+			t.Errorf("request id should be forwarded in header, got %s", h)
+		}
+	}))
+
+	testcases := map[string]struct {
+		header string
+		keep   bool
+	}{
+		"set by client": {header: "abc-123", keep: true},
+		"not set":       {header: ""},
+		"too long":      {header: strings.Repeat("a", maxRequestIDLength+1)},
+		"not printable": {header: "abc\n123"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+			req.Header.Set(RequestIDHeader, tc.header)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got == "" || w.Header().Get(RequestIDHeader) != got {
+				t.Errorf("request id %q should be returned in response, got %q", got, w.Header().Get(RequestIDHeader))
+			}
+			if (got == tc.header) != tc.keep {
+				t.Errorf("request id %q of client is kept: %v, expect %v", tc.header, got == tc.header, tc.keep)
+			}
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	defer SetSampling(5, 100)
+	SetSampling(2, 3)
+
+	now := time.Unix(100, 0)
+	var logged []int
+	for i := 1; i <= 10; i++ {
+		if sample("proxy/list", now) {
+			logged = append(logged, i)
+		}
+	}
+	// the first 2, then every 3rd
+	if expect := []int{1, 2, 5, 8}; !equal(logged, expect) {
+		t.Errorf("expect %v are logged, got %v", expect, logged)
+	}
+	if !sample("proxy/get", now) {
+		t.Errorf("keys should be sampled separately")
+	}
+	if !sample("proxy/list", now.Add(time.Second)) {
+		t.Errorf("count should be reset in next second")
+	}
+
+	SetSampling(0, 0)
+	for i := 0; i < 10; i++ {
+		if !sample("proxy/list", now) {
+			t.Fatalf("all entries should be logged if sampling is disabled")
+		}
+	}
+}
+
+func TestSubsystemVerbosity(t *testing.T) {
+	defer SetSubsystemVerbosity(nil)
+	SetSubsystemVerbosity(map[string]int{Cache: 4})
+
+	if !For(Cache).V(4).Enabled() {
+		t.Errorf("level 4 of cache should be enabled")
+	}
+	if For(Cache).V(5).Enabled() {
+		t.Errorf("level 5 of cache should not be enabled")
+	}
+	if For(Remote).V(4).Enabled() {
+		t.Errorf("level 4 of remote should not be enabled")
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
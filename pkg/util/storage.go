@@ -168,6 +168,55 @@ func (ds *diskStorage) get(path string) ([]byte, error) {
 	return nil, fmt.Errorf("%s is exist, but not recognized, %v", path, info.Mode())
 }
 
+// List return keys of files under prefix, temporary files are skipped
+func (ds *diskStorage) List(prefix string) ([]storage.KeyInfo, error) {
+	keys := make([]storage.KeyInfo, 0)
+	dir := filepath.Join(ds.baseDir, prefix)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || isTmpFile(path) {
+			return nil
+		}
+		key, err := filepath.Rel(ds.baseDir, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, storage.KeyInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil && os.IsNotExist(err) {
+		return keys, nil
+	}
+	return keys, err
+}
+
+// Delete remove the file of key, directories are not removed
+func (ds *diskStorage) Delete(key string) error {
+	if key == "" {
+		return storage.ErrKeyIsEmpty
+	}
+
+	if !ds.lockKey(key) {
+		return storage.ErrStorageAccessConflict
+	}
+	defer ds.unLockKey(key)
+
+	path := filepath.Join(ds.baseDir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrStorageNotFound
+		}
+		return err
+	}
+	if info.IsDir() {
+		return storage.ErrKeyHasNoContent
+	}
+	return os.Remove(path)
+}
+
 // Recover recover storage error
 func (ds *diskStorage) Recover(key string) error {
 	if !ds.lockKey(key) {
@@ -18,7 +18,10 @@ limitations under the License.
 
 package storage
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // ErrStorageAccessConflict is an error for accessing key conflict
 var ErrStorageAccessConflict = errors.New("specified key is under accessing")
@@ -32,8 +35,19 @@ var ErrKeyHasNoContent = errors.New("specified key has no contents")
 // ErrKeyIsEmpty is an error for key is empty
 var ErrKeyIsEmpty = errors.New("specified key is empty")
 
+// KeyInfo metadata of contents stored with a key
+type KeyInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
 // Store is an interface for caching data into backend storage
 type Store interface {
 	Create(key string, contents []byte) error
 	Get(key string) ([]byte, error)
+	// List return keys with contents under prefix, all keys are returned if prefix is empty
+	List(prefix string) ([]KeyInfo, error)
+	// Delete delete contents of key, ErrStorageNotFound is returned if it doesn't exist
+	Delete(key string) error
 }
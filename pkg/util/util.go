@@ -20,14 +20,171 @@ package util
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
+	json "github.com/json-iterator/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog/v2"
+
+	"code.aliyun.com/openyurt/edge-proxy/pkg/util/logs"
+)
+
+// ProxyKeyType represents the key of context
+type ProxyKeyType int
+
+const (
+	// ProxyReqComponent represents request client component in context
+	ProxyReqComponent ProxyKeyType = iota
+	// ProxyReqClass represents request class in context
+	ProxyReqClass
+	// ProxyServingSource represents the recorder of serving source in context
+	ProxyServingSource
 )
 
+// sources where responses are served from
+const (
+	// SourceRemote response is proxied from kube-apiserver
+	SourceRemote = "remote"
+	// SourceLocal response is served by local proxy when kube-apiserver is unhealthy
+	SourceLocal = "local"
+	// SourceCache response is served from cache when kube-apiserver is healthy, like memory cache and stale cache
+	SourceCache = "cache"
+)
+
+// gzipLog ungzipping happens for every compressed response, so its logs are sampled
+var gzipLog = logs.For(logs.Remote)
+
+// DefaultComponent is used when the component of client can not be recognized
+const DefaultComponent = "default"
+
+// nodeUserPrefix prefix of user names of nodes, they are authenticated as kubelet
+const nodeUserPrefix = "system:node:"
+
+// reservedComponents top level directories of disk cache which are not cached lists,
+// like leases, queued requests and certificates, a component with the same name would share keys with them.
+var reservedComponents = sets.NewString("leases", "queue", "pki")
+
+// IsReservedComponent check name is reserved for contents other than cached lists in disk cache
+func IsReservedComponent(name string) bool {
+	return reservedComponents.Has(name)
+}
+
+// WithClientComponent returns a copy of parent in which the client component value is set
+func WithClientComponent(parent context.Context, component string) context.Context {
+	return context.WithValue(parent, ProxyReqComponent, component)
+}
+
+// ClientComponentFrom returns the value of the client component key on the ctx
+func ClientComponentFrom(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(ProxyReqComponent).(string)
+	return info, ok
+}
+
+// WithRequestClientComponent add component field into request context,
+// component is derived from the authenticated user, or the first part of User-Agent
+// like kubelet, kube-proxy and flanneld for unauthenticated requests,
+// so cache of different clients can be isolated.
+func WithRequestClientComponent(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(WithClientComponent(req.Context(), clientComponent(req)))
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// clientComponent get component of request from the authenticated user, User-Agent is only used
+// for unauthenticated requests, because it can be set to anything by callers.
+func clientComponent(req *http.Request) string {
+	if u, ok := apirequest.UserFrom(req.Context()); ok && u.GetName() != "" {
+		return componentFromUser(u)
+	}
+	return componentFromUserAgent(req.Header.Get("User-Agent"))
+}
+
+// componentFromUser get component from user name, nodes like "system:node:node-a" are kubelet,
+// others like "system:serviceaccount:kube-system:kube-proxy" are used as they are.
+func componentFromUser(u user.Info) string {
+	if strings.HasPrefix(u.GetName(), nodeUserPrefix) {
+		return "kubelet"
+	}
+	return sanitizeComponent(u.GetName())
+}
+
+// componentFromUserAgent get component from User-Agent like "kubelet/v1.22.3 (linux/amd64) kubernetes/c920368"
+func componentFromUserAgent(userAgent string) string {
+	return sanitizeComponent(strings.Split(userAgent, "/")[0])
+}
+
+// sanitizeComponent component will be used as a part of cache key, so only [a-z0-9-_.] is kept.
+func sanitizeComponent(name string) string {
+	comp := strings.ToLower(strings.TrimSpace(name))
+	comp = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, comp)
+	if comp == "" || comp == "." || comp == ".." || IsReservedComponent(comp) {
+		return DefaultComponent
+	}
+	return comp
+}
+
+// WithServingSourceRecorder returns a copy of parent in which the serving source can be recorded by SetServingSource,
+// parent is returned if it has a recorder already, so the source is shared by audit and access logs.
+func WithServingSourceRecorder(parent context.Context) context.Context {
+	if _, ok := parent.Value(ProxyServingSource).(*string); ok {
+		return parent
+	}
+	return context.WithValue(parent, ProxyServingSource, new(string))
+}
+
+// SetServingSource record where the response is served from, it's ignored if there is no recorder in ctx
+func SetServingSource(ctx context.Context, source string) {
+	if recorder, ok := ctx.Value(ProxyServingSource).(*string); ok {
+		*recorder = source
+	}
+}
+
+// ServingSourceFrom returns the serving source recorded in ctx
+func ServingSourceFrom(ctx context.Context) (string, bool) {
+	recorder, ok := ctx.Value(ProxyServingSource).(*string)
+	if !ok || *recorder == "" {
+		return "", false
+	}
+	return *recorder, true
+}
+
+// WriteErrStatus write err as a metav1.Status to w
+func WriteErrStatus(w http.ResponseWriter, err error) {
+	status := apierrors.NewInternalError(err).ErrStatus
+	if apiStatus, ok := err.(apierrors.APIStatus); ok {
+		status = apiStatus.Status()
+	}
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		klog.Errorf("marshal status err: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	if _, err = w.Write(data); err != nil {
+		klog.Errorf("rw.Write err: %v", err)
+	}
+}
+
 // ReqInfoString formats a string for request info
 func ReqInfoString(info *apirequest.RequestInfo) string {
 	if info == nil {
@@ -95,6 +252,56 @@ func (dr *dualReadCloser) Close() error {
 	return nil
 }
 
+// NewMultiReadCloser fan out rc to n readers like dualReadCloser, rc is read only once,
+// and it's closed when it's read to the end or all readers are closed.
+// readers should be read concurrently, because a slow reader blocks the others.
+func NewMultiReadCloser(rc io.ReadCloser, n int) []io.ReadCloser {
+	readers := make([]io.ReadCloser, n)
+	writers := make([]*io.PipeWriter, n)
+	for i := range readers {
+		pr, pw := io.Pipe()
+		readers[i], writers[i] = pr, pw
+	}
+
+	go func() {
+		defer rc.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			nr, err := rc.Read(buf)
+			if nr > 0 {
+				alive := 0
+				for i, w := range writers {
+					if w == nil {
+						continue
+					}
+					// reader is closed, skip it
+					if _, werr := w.Write(buf[:nr]); werr != nil {
+						writers[i] = nil
+						continue
+					}
+					alive++
+				}
+				if alive == 0 {
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				for _, w := range writers {
+					if w != nil {
+						w.CloseWithError(err)
+					}
+				}
+				return
+			}
+		}
+	}()
+
+	return readers
+}
+
 // gzipReaderCloser will gunzip the data if response header
 // contains Content-Encoding=gzip header.
 type gzipReaderCloser struct {
@@ -128,7 +335,7 @@ func NewGZipReaderCloser(header http.Header, body io.ReadCloser, info *apireques
 		return body, false
 	}
 
-	klog.Infof("response of %s will be ungzip at %s", ReqInfoString(info), caller)
+	gzipLog.V(5).Sampled(caller).InfoS("Response will be ungzipped", "request", ReqInfoString(info), "caller", caller)
 	return &gzipReaderCloser{
 		body: body,
 	}, true
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestComponentFromUserAgent(t *testing.T) {
+	tests := map[string]string{
+		"kubelet/v1.22.3 (linux/amd64) kubernetes/c920368": "kubelet",
+		"Kube-Proxy/v1.22.3": "kube-proxy",
+		"flanneld":           "flanneld",
+		"my agent:1.0/x":     "my_agent_1.0",
+		"":                   DefaultComponent,
+		"..":                 DefaultComponent,
+		"leases/v1":          DefaultComponent,
+		"pki":                DefaultComponent,
+	}
+	for userAgent, expected := range tests {
+		if comp := componentFromUserAgent(userAgent); comp != expected {
+			t.Errorf("User-Agent %q, expect %q, got %q", userAgent, expected, comp)
+		}
+	}
+}
+
+func TestClientComponent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		user      user.Info
+		expected  string
+	}{
+		{"unauthenticated", "kubelet/v1.22.3", nil, "kubelet"},
+		{"node", "kubelet/v1.22.3", &user.DefaultInfo{Name: "system:node:node-a"}, "kubelet"},
+		{"spoofed user agent", "kubelet/v1.22.3", &user.DefaultInfo{Name: "system:serviceaccount:default:app"}, "system_serviceaccount_default_app"},
+		{"anonymous", "kubelet/v1.22.3", &user.DefaultInfo{Name: user.Anonymous}, "system_anonymous"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/pods", nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		if tt.user != nil {
+			req = req.WithContext(apirequest.WithUser(req.Context(), tt.user))
+		}
+		if comp := clientComponent(req); comp != tt.expected {
+			t.Errorf("%s: expect %q, got %q", tt.name, tt.expected, comp)
+		}
+	}
+}